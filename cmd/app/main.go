@@ -1,16 +1,28 @@
 package main
 
 import (
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/service"
 	"bookstore_api/internal/infrastructure/http/handler"
 	"bookstore_api/internal/infrastructure/http/route"
+	"bookstore_api/internal/infrastructure/payment"
 	"bookstore_api/internal/infrastructure/postgres"
 	"bookstore_api/internal/infrastructure/redis"
+	"bookstore_api/internal/infrastructure/storage"
 	"bookstore_api/internal/repositories"
 	"bookstore_api/internal/services"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/joho/godotenv"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func runServer() error {
@@ -41,14 +53,20 @@ func runServer() error {
 		return err
 	}
 
+	// Background jobs run until the server is told to stop, and it waits for them to wind down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var jobs sync.WaitGroup
+
 	handler := handler.NewHandler(cache.GetCache())
-	service, err := services.NewService()
+	legacyService, err := services.NewService()
 	if err != nil {
 		return err
 	}
 	repository := repositories.NewRepository(database.GetDB())
 
-	routers := route.NewRouter()
+	denylist := redis.NewDenylist(cache.GetCache())
+	routers := route.NewRouter(denylist)
 	routers.Mux.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -58,21 +76,133 @@ func runServer() error {
 		}
 	})
 
+	routers.RegisterKeyRoutes()
+
 	// Diff
 	bookRepo := postgres.NewBookRepository(database)
 	routers.RegisterBookRoutes(bookRepo)
+
+	coverStorage, err := storage.NewLocalStorage()
+	if err != nil {
+		return err
+	}
+	routers.RegisterCoverRoutes(bookRepo, coverStorage)
+
+	authorRepo := postgres.NewAuthorRepository(database)
+	routers.RegisterAuthorRoutes(authorRepo, bookRepo)
+
+	categoryRepo := postgres.NewCategoryRepository(database)
+	routers.RegisterCategoryRoutes(categoryRepo, bookRepo)
+
+	reviewRepo := postgres.NewReviewRepository(database)
+	routers.RegisterReviewRoutes(reviewRepo, bookRepo)
+
+	addressRepo := postgres.NewAddressRepository(database)
+	routers.RegisterAddressRoutes(addressRepo)
+
+	orderRepo := postgres.NewOrderRepository(database)
+	routers.RegisterOrderRoutes(orderRepo, bookRepo, addressRepo)
+
+	// Only fake providers for now, the real ones plug in here keyed by their payment method
+	paymentRepo := postgres.NewPaymentRepository(database)
+	routers.RegisterPaymentRoutes(paymentRepo, orderRepo,
+		payment.NewFakeProvider(orders.PayPal),
+		payment.NewFakeProvider(orders.Bank),
+		payment.NewFakeProvider(orders.QRIS),
+	)
 	//
 
-	routers.RegisterUserRoutes(handler, service, repository)
+	routers.RegisterUserRoutes(handler, legacyService, repository)
 
-	err = http.ListenAndServe(":8081", routers.Mux)
+	// Covers written with an older key are moved to the current one in the background, 0 turns it off
+	rotationInterval, err := jobInterval("KEY_ROTATION_INTERVAL")
 	if err != nil {
 		return err
 	}
+	if rotationInterval > 0 {
+		bookService, err := service.NewBookService(bookRepo)
+		if err != nil {
+			return err
+		}
+
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			bookService.RunCoverRotation(ctx, rotationInterval)
+		}()
+	}
+
+	// Revoked and expired sessions are deleted in the background, 0 turns it off
+	sweepInterval, err := jobInterval("SESSION_SWEEP_INTERVAL")
+	if err != nil {
+		return err
+	}
+	if sweepInterval > 0 {
+		sweepBatchSize := services.DefaultSweepBatchSize
+		if value := os.Getenv("SESSION_SWEEP_BATCH_SIZE"); value != "" {
+			sweepBatchSize, err = strconv.Atoi(value)
+			if err != nil || sweepBatchSize <= 0 {
+				return errors.New("SESSION_SWEEP_BATCH_SIZE must be a positive number")
+			}
+		}
+
+		sessionService := services.NewSessionService(legacyService, repositories.NewSessionRepository(repository), denylist)
+
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			sessionService.RunSessionSweeper(ctx, sweepInterval, sweepBatchSize)
+		}()
+	}
+
+	server := &http.Server{
+		Addr:    ":8081",
+		Handler: routers.Mux,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Printf("error shutting down server: %v", err)
+		}
+	}()
+
+	err = server.ListenAndServe()
+
+	// The jobs stop with the server, whichever way it went down
+	stop()
+	jobs.Wait()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 
 	return nil
 }
 
+// shutdownTimeout is how long requests in flight get to finish once the server is told to stop
+const shutdownTimeout = 10 * time.Second
+
+// jobInterval reads how often a background job runs, an hour unless set
+func jobInterval(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return time.Hour, nil
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration like 30m: %v", name, err)
+	}
+
+	return interval, nil
+}
+
 func main() {
 	err := godotenv.Load(".env")
 	if err != nil {
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bookstore_api/internal/core/service"
+	"bookstore_api/internal/infrastructure/postgres"
+	"bufio"
+	"context"
+	"flag"
+	"github.com/joho/godotenv"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Exports the catalog the same way GET /books/export does, for the nightly distributor feed
+// go run ./cmd/export -format onix -file books.xml
+func runExport(path string, format string) error {
+	if format == "" {
+		format = filepath.Ext(path)
+	}
+	exportFormat, err := service.NewExportFormat(format)
+	if err != nil {
+		return err
+	}
+
+	database, err := postgres.New()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	bookService, err := service.NewBookService(postgres.NewBookRepository(database))
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		out := bufio.NewWriter(os.Stdout)
+		err = bookService.ExportBooks(context.Background(), out, exportFormat)
+		if err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+
+	// Written next to the target and renamed over it, so a failed run never leaves half a feed behind
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	out := bufio.NewWriter(file)
+	err = bookService.ExportBooks(context.Background(), out, exportFormat)
+	if err != nil {
+		return err
+	}
+	if err = out.Flush(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func main() {
+	path := flag.String("file", "", "where to write the feed, stdout when empty")
+	format := flag.String("format", "", "csv, jsonl or onix, taken from the file extension when empty")
+	flag.Parse()
+
+	if *path == "" && *format == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	err = runExport(*path, *format)
+	if err != nil {
+		log.Fatalf("Error exporting books, %s", err)
+	}
+}
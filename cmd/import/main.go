@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bookstore_api/internal/core/service"
+	"bookstore_api/internal/infrastructure/postgres"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Imports a catalog feed the same way POST /books/import does, for feeds too big to upload
+// go run ./cmd/import -file feed.csv
+func runImport(path string, format string, verbose bool) error {
+	if format == "" {
+		format = filepath.Ext(path)
+	}
+	importFormat, err := service.NewImportFormat(format)
+	if err != nil {
+		return err
+	}
+
+	feed, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer feed.Close()
+
+	database, err := postgres.New()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	bookService, err := service.NewBookService(postgres.NewBookRepository(database))
+	if err != nil {
+		return err
+	}
+
+	report, err := bookService.ImportBooks(context.Background(), feed, importFormat)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range report.Lines {
+		switch {
+		case line.Err != nil:
+			fmt.Printf("line %d: %s %q: %v\n", line.Line, line.Status, line.Title, line.Err)
+		case verbose:
+			fmt.Printf("line %d: %s %q (id %d)\n", line.Line, line.Status, line.Title, line.BookID)
+		}
+	}
+	fmt.Printf("%d created, %d updated, %d rejected\n", report.Created, report.Updated, report.Rejected)
+
+	return nil
+}
+
+func main() {
+	path := flag.String("file", "", "csv or jsonl feed to import")
+	format := flag.String("format", "", "csv or jsonl, taken from the file extension when empty")
+	verbose := flag.Bool("v", false, "report every line, not just the rejected ones")
+	flag.Parse()
+
+	if *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	err = runImport(*path, *format, *verbose)
+	if err != nil {
+		log.Fatalf("Error importing books, %s", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Adds a new master key to the file the local key manager reads, the new key wraps every data key from then on.
+// Point KMS_MASTER_KEY_FILE at the file, then run ./cmd/rotatekeys to move the covers over.
+// go run ./cmd/masterkey -file kms/master.key
+func addMasterKey(name string, id string) error {
+	if id == "" {
+		id = time.Now().UTC().Format("20060102150405")
+	}
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+
+	err := os.MkdirAll(filepath.Dir(name), 0o700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s:%s\n", id, base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("added master key %q to %s\n", id, name)
+	return nil
+}
+
+func main() {
+	name := flag.String("file", "kms/master.key", "master key file to add the key to")
+	id := flag.String("id", "", "ID of the new key, defaults to the current time")
+	flag.Parse()
+
+	err := addMasterKey(*name, *id)
+	if err != nil {
+		log.Fatalf("Error adding master key, %s", err)
+	}
+}
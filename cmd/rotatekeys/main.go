@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bookstore_api/internal/core/service"
+	"bookstore_api/internal/infrastructure/postgres"
+	"context"
+	"fmt"
+	"github.com/joho/godotenv"
+	"log"
+)
+
+// Re-encrypts every cover with the current key in one pass, to finish a rotation before an old key is dropped.
+// Once it reports nothing failed, the old key can be removed from AES_KEYS or the master key file.
+// go run ./cmd/rotatekeys
+func runRotation() error {
+	database, err := postgres.New()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	bookService, err := service.NewBookService(postgres.NewBookRepository(database))
+	if err != nil {
+		return err
+	}
+
+	report, err := bookService.RotateCovers(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d covers rotated, %d failed\n", report.Rotated, report.Failed)
+	if report.Failed > 0 {
+		return fmt.Errorf("%d covers could not be decrypted with any key", report.Failed)
+	}
+
+	return nil
+}
+
+func main() {
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	err = runRotation()
+	if err != nil {
+		log.Fatalf("Error rotating keys, %s", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Generates a key pair to sign tokens with, the private key for JWT_SIGNING_KEYS and the public one for JWT_VERIFICATION_KEYS
+// once the key is retired. Add it to the end of JWT_SIGNING_KEYS to start signing with it.
+// go run ./cmd/signingkey -out keys/2025q1.pem
+func generateKey(name string, keyType string) error {
+	var signer crypto.Signer
+	var err error
+
+	switch strings.ToLower(keyType) {
+	case "ed25519", "eddsa":
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	case "rsa", "rs256":
+		signer, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return fmt.Errorf("unknown key type %q, use ed25519 or rsa", keyType)
+	}
+	if err != nil {
+		return err
+	}
+
+	private, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return err
+	}
+	public, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o700)
+	if err != nil {
+		return err
+	}
+
+	// O_EXCL so an existing key is never overwritten
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: private})
+	if err != nil {
+		return err
+	}
+
+	publicName := strings.TrimSuffix(name, filepath.Ext(name)) + ".pub.pem"
+	err = os.WriteFile(publicName, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0o644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s and %s\n", name, publicName)
+	return nil
+}
+
+func main() {
+	name := flag.String("out", "", "file to write the private key to, the public key goes next to it")
+	keyType := flag.String("type", "ed25519", "ed25519 or rsa")
+	flag.Parse()
+
+	if *name == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := generateKey(*name, *keyType)
+	if err != nil {
+		log.Fatalf("Error generating signing key, %s", err)
+	}
+}
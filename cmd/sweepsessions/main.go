@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bookstore_api/internal/infrastructure/postgres"
+	"bookstore_api/internal/repositories"
+	"bookstore_api/internal/services"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Deletes revoked and expired sessions in one pass, for running from cron when the server's own sweeper is turned off
+// go run ./cmd/sweepsessions -batch 1000
+func runSweep(batchSize int) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	database, err := postgres.New()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	service, err := services.NewService()
+	if err != nil {
+		return err
+	}
+	// Sweeping only deletes sessions that are already revoked or expired, so it has no tokens to deny
+	sessionRepository := repositories.NewSessionRepository(repositories.NewRepository(database.GetDB()))
+	sessionService := services.NewSessionService(service, sessionRepository, nil)
+
+	// Whatever was deleted before an interruption stays deleted, so the counts are printed either way
+	report, err := sessionService.SweepSessions(ctx, batchSize)
+	fmt.Printf("%d revoked and %d expired sessions deleted\n", report.Revoked, report.Expired)
+
+	return err
+}
+
+func main() {
+	batchSize := flag.Int("batch", services.DefaultSweepBatchSize, "sessions to delete per statement")
+	flag.Parse()
+
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	err = runSweep(*batchSize)
+	if err != nil {
+		log.Fatalf("Error sweeping sessions, %s", err)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE OrderBooks DROP COLUMN IF EXISTS unit_price;
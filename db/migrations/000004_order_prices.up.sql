@@ -0,0 +1,2 @@
+-- Snapshot of the book price at the time the order was placed
+ALTER TABLE OrderBooks ADD COLUMN unit_price DECIMAL(10, 2);
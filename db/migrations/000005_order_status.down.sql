@@ -0,0 +1,5 @@
+ALTER TABLE Books DROP CONSTRAINT IF EXISTS books_stock_non_negative;
+
+ALTER TABLE Orders DROP COLUMN IF EXISTS status;
+
+DROP TYPE IF EXISTS ORDER_STATUS;
@@ -0,0 +1,6 @@
+CREATE TYPE ORDER_STATUS AS ENUM ('placed', 'canceled');
+
+ALTER TABLE Orders ADD COLUMN status ORDER_STATUS DEFAULT 'placed' NOT NULL;
+
+-- Stock can never be oversold, even if a query forgets to check it
+ALTER TABLE Books ADD CONSTRAINT books_stock_non_negative CHECK (stock >= 0);
@@ -0,0 +1,13 @@
+ALTER TABLE Orders DROP CONSTRAINT IF EXISTS orders_payment_result_unique;
+
+ALTER TABLE PaymentResults
+    DROP COLUMN IF EXISTS canceled_at,
+    DROP COLUMN IF EXISTS completed_at,
+    DROP COLUMN IF EXISTS provider_reference,
+    DROP COLUMN IF EXISTS amount,
+    DROP COLUMN IF EXISTS payment_method,
+    ALTER COLUMN status DROP NOT NULL,
+    ALTER COLUMN status DROP DEFAULT;
+
+-- Enum values can't be dropped, 'paid' orders fall back to placed
+UPDATE Orders SET status = 'placed' WHERE status = 'paid';
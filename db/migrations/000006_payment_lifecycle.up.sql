@@ -0,0 +1,13 @@
+ALTER TYPE ORDER_STATUS ADD VALUE IF NOT EXISTS 'paid';
+
+ALTER TABLE PaymentResults
+    ALTER COLUMN status SET DEFAULT 'pending',
+    ALTER COLUMN status SET NOT NULL,
+    ADD COLUMN payment_method     PAYMENT_METHOD,
+    ADD COLUMN amount             DECIMAL(10, 2),
+    ADD COLUMN provider_reference VARCHAR(255),
+    ADD COLUMN completed_at       TIMESTAMP,
+    ADD COLUMN canceled_at        TIMESTAMP;
+
+-- An order only ever points to a single payment
+ALTER TABLE Orders ADD CONSTRAINT orders_payment_result_unique UNIQUE (payment_result_id);
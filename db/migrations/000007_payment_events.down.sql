@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS paymentresults_provider_reference_idx;
+
+DROP TABLE IF EXISTS PaymentEvents;
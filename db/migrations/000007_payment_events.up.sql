@@ -0,0 +1,12 @@
+-- Every webhook event we've processed, the primary key de-duplicates redeliveries
+CREATE TABLE PaymentEvents (
+    provider          PAYMENT_METHOD NOT NULL,
+    event_id          VARCHAR(255)   NOT NULL,
+    payment_result_id INT REFERENCES PaymentResults(id) ON DELETE CASCADE,
+    status            PAYMENT_STATUS NOT NULL,
+    applied           BOOLEAN   DEFAULT FALSE NOT NULL,
+    received_at       TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (provider, event_id)
+);
+
+CREATE UNIQUE INDEX paymentresults_provider_reference_idx ON PaymentResults (provider_reference);
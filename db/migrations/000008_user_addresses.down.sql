@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS addresses_user_id_idx;
+
+DROP INDEX IF EXISTS addresses_user_default_idx;
+
+ALTER TABLE Addresses
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS is_default,
+    DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,11 @@
+-- Addresses with a user_id make up the address book, the ones without are snapshots owned by an order
+ALTER TABLE Addresses
+    ADD COLUMN user_id    INT REFERENCES Users(id) ON DELETE CASCADE,
+    ADD COLUMN is_default BOOLEAN   DEFAULT FALSE NOT NULL,
+    ADD COLUMN created_at TIMESTAMP DEFAULT NOW(),
+    ADD COLUMN updated_at TIMESTAMP DEFAULT NOW();
+
+-- Only one default address per user
+CREATE UNIQUE INDEX addresses_user_default_idx ON Addresses (user_id) WHERE is_default;
+
+CREATE INDEX addresses_user_id_idx ON Addresses (user_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS reviews_book_id_idx;
+
+DROP INDEX IF EXISTS reviews_user_book_idx;
+
+ALTER TABLE Reviews
+    DROP CONSTRAINT IF EXISTS reviews_rating_range,
+    ALTER COLUMN book_id DROP NOT NULL,
+    ALTER COLUMN user_id DROP NOT NULL,
+    ALTER COLUMN rating DROP NOT NULL;
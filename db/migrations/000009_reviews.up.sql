@@ -0,0 +1,14 @@
+-- Clear out anything the unique index and checks below would reject
+DELETE FROM Reviews WHERE rating IS NULL OR rating NOT BETWEEN 1 AND 5 OR user_id IS NULL OR book_id IS NULL;
+DELETE FROM Reviews a USING Reviews b WHERE a.user_id = b.user_id AND a.book_id = b.book_id AND a.id < b.id;
+
+ALTER TABLE Reviews
+    ALTER COLUMN rating SET NOT NULL,
+    ALTER COLUMN user_id SET NOT NULL,
+    ALTER COLUMN book_id SET NOT NULL,
+    ADD CONSTRAINT reviews_rating_range CHECK (rating BETWEEN 1 AND 5);
+
+-- One review per user per book
+CREATE UNIQUE INDEX reviews_user_book_idx ON Reviews (user_id, book_id);
+
+CREATE INDEX reviews_book_id_idx ON Reviews (book_id);
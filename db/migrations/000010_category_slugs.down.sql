@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS bookcategory_category_id_idx;
+
+ALTER TABLE Categories DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,9 @@
+ALTER TABLE Categories ADD COLUMN slug VARCHAR(100);
+
+UPDATE Categories SET slug = trim(BOTH '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g'));
+
+ALTER TABLE Categories
+    ALTER COLUMN slug SET NOT NULL,
+    ADD CONSTRAINT categories_slug_unique UNIQUE (slug);
+
+CREATE INDEX bookcategory_category_id_idx ON BookCategory (category_id);
@@ -0,0 +1,7 @@
+-- Titles weigh more than synopses when ranking
+ALTER TABLE Books ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(synopsis, '')), 'B')
+) STORED;
+
+CREATE INDEX books_search_vector_idx ON Books USING GIN (search_vector);
@@ -0,0 +1,8 @@
+-- Slugs a book used to have, so links shared before a rename keep working
+CREATE TABLE BookSlugHistory (
+    slug VARCHAR(255) PRIMARY KEY,
+    book_id INT NOT NULL REFERENCES Books(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX bookslughistory_book_id_idx ON BookSlugHistory (book_id);
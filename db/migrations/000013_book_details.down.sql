@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS BookAuthor;
+DROP TABLE IF EXISTS Authors;
+
+DROP INDEX IF EXISTS books_title_without_isbn_idx;
+ALTER TABLE Books ADD CONSTRAINT books_title_key UNIQUE (title);
+
+ALTER TABLE Books
+    DROP COLUMN IF EXISTS isbn,
+    DROP COLUMN IF EXISTS publisher,
+    DROP COLUMN IF EXISTS page_count,
+    DROP COLUMN IF EXISTS language,
+    DROP COLUMN IF EXISTS published_on;
@@ -0,0 +1,29 @@
+ALTER TABLE Books
+    ADD COLUMN isbn VARCHAR(13) UNIQUE,
+    ADD COLUMN publisher VARCHAR(255),
+    ADD COLUMN page_count INT CHECK (page_count > 0),
+    ADD COLUMN language VARCHAR(35),
+    ADD COLUMN published_on DATE;
+
+-- Editions share a title and tell apart by ISBN, only books without one still need a unique title
+ALTER TABLE Books DROP CONSTRAINT books_title_key;
+CREATE UNIQUE INDEX books_title_without_isbn_idx ON Books (title) WHERE isbn IS NULL;
+
+CREATE TABLE Authors (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(255) UNIQUE NOT NULL,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- position keeps the authors in the order the cover lists them
+CREATE TABLE BookAuthor (
+    book_id INT REFERENCES Books(id) ON DELETE CASCADE,
+    author_id INT REFERENCES Authors(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    PRIMARY KEY (book_id, author_id)
+);
+
+CREATE INDEX bookauthor_author_id_idx ON BookAuthor (author_id);
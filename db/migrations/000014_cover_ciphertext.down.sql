@@ -0,0 +1 @@
+ALTER TABLE Books ALTER COLUMN cover_image TYPE VARCHAR(255);
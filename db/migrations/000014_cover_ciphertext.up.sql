@@ -0,0 +1,2 @@
+-- Ciphertexts now carry the ID of their key, and base64 already made long URLs outgrow 255 characters
+ALTER TABLE Books ALTER COLUMN cover_image TYPE TEXT;
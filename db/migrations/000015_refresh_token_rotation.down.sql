@@ -0,0 +1,5 @@
+-- Only hashes were kept, so the tokens can't be put back and every session has to sign in again
+ALTER TABLE Sessions ADD COLUMN refresh_token VARCHAR(512) NOT NULL DEFAULT '';
+UPDATE Sessions SET is_revoked = TRUE;
+
+DROP TABLE IF EXISTS RefreshTokens;
@@ -0,0 +1,18 @@
+-- Every refresh token a session was handed, by hash only. The one not rotated yet is the only one still usable,
+-- a rotated one coming back means it was stolen and the session gets revoked.
+CREATE TABLE RefreshTokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    session_id VARCHAR(255) NOT NULL REFERENCES Sessions(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    rotated_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX refreshtokens_current_idx ON RefreshTokens (session_id) WHERE rotated_at IS NULL;
+
+-- Sessions still open keep working, their token is carried over as its hash
+INSERT INTO RefreshTokens (token_hash, session_id, created_at)
+SELECT encode(sha256(convert_to(refresh_token, 'UTF8')), 'hex'), id, COALESCE(created_at, NOW())
+FROM Sessions
+WHERE NOT is_revoked;
+
+ALTER TABLE Sessions DROP COLUMN refresh_token;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS sessions_user_email_idx;
+
+ALTER TABLE Sessions DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE Sessions DROP COLUMN IF EXISTS user_agent;
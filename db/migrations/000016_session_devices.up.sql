@@ -0,0 +1,5 @@
+-- What signed in, so users can tell their sessions apart when kicking one out
+ALTER TABLE Sessions ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '';
+ALTER TABLE Sessions ADD COLUMN ip_address VARCHAR(45) NOT NULL DEFAULT '';
+
+CREATE INDEX sessions_user_email_idx ON Sessions (user_email);
@@ -0,0 +1,166 @@
+package addresses
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Custom errors for domain rules
+var (
+	ErrInvalidID         = errors.New("invalid ID")
+	ErrInvalidAddress    = errors.New("address and city are required")
+	ErrInvalidCountry    = errors.New("country must be an ISO 3166-1 alpha-2 code")
+	ErrInvalidPostalCode = errors.New("invalid postal code for country")
+)
+
+// isoCountries holds every ISO 3166-1 alpha-2 code
+var isoCountries = strings.Fields(`
+	AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ
+	CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR
+	GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO
+	JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR
+	MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO
+	RS RU RW SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV
+	TW TZ UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW
+`)
+
+// postalCodeFormats are the countries we ship to the most, everyone else gets the generic format
+var postalCodeFormats = map[string]*regexp.Regexp{
+	"ID": regexp.MustCompile(`^\d{5}$`),
+	"MY": regexp.MustCompile(`^\d{5}$`),
+	"SG": regexp.MustCompile(`^\d{6}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+}
+
+var genericPostalCode = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,9}$`)
+
+// Value Objects
+
+type ID int64
+
+func (i ID) Get() int64 {
+	return int64(i)
+}
+
+type Country string
+
+func NewCountry(value string) (Country, error) {
+	code := strings.ToUpper(strings.TrimSpace(value))
+	for _, country := range isoCountries {
+		if country == code {
+			return Country(code), nil
+		}
+	}
+	return "", ErrInvalidCountry
+}
+
+func (c Country) Get() string {
+	return string(c)
+}
+
+type PostalCode string
+
+func NewPostalCode(country Country, value string) (PostalCode, error) {
+	code := strings.ToUpper(strings.TrimSpace(value))
+
+	format, ok := postalCodeFormats[country.Get()]
+	if !ok {
+		format = genericPostalCode
+	}
+	if !format.MatchString(code) {
+		return "", ErrInvalidPostalCode
+	}
+
+	return PostalCode(code), nil
+}
+
+func (p PostalCode) Get() string {
+	return string(p)
+}
+
+// Address Entity - Aggregate Root
+type Address struct {
+	ID         ID
+	UserID     int64
+	Address    string
+	City       string
+	PostalCode PostalCode
+	Country    Country
+	IsDefault  bool
+	CreatedAt  *time.Time
+	UpdatedAt  *time.Time
+}
+
+// NewAddress Factory Method to create a new, validated address
+func NewAddress(address, city, postalCode, country string) (*Address, error) {
+	address = strings.TrimSpace(address)
+	city = strings.TrimSpace(city)
+	if address == "" || city == "" {
+		return nil, ErrInvalidAddress
+	}
+
+	newCountry, err := NewCountry(country)
+	if err != nil {
+		return nil, err
+	}
+	newPostalCode, err := NewPostalCode(newCountry, postalCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Address{
+		Address:    address,
+		City:       city,
+		PostalCode: newPostalCode,
+		Country:    newCountry,
+	}, nil
+}
+
+// Snapshot copies the address without its identity, so orders keep the address as it was at checkout
+func (a *Address) Snapshot() *Address {
+	return &Address{
+		Address:    a.Address,
+		City:       a.City,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
+
+// Replace overwrites the location with the one of another address, keeping the identity and owner
+func (a *Address) Replace(other *Address) {
+	a.Address = other.Address
+	a.City = other.City
+	a.PostalCode = other.PostalCode
+	a.Country = other.Country
+	a.MarkUpdated()
+}
+
+func (a *Address) Create(id int64) error {
+	if id < 0 {
+		return ErrInvalidID
+	}
+
+	a.ID = ID(id)
+
+	if a.CreatedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	a.CreatedAt = &now
+
+	return nil
+}
+
+// MarkUpdated Helper method to mark when the entity is updated
+func (a *Address) MarkUpdated() {
+	now := time.Now().UTC()
+	a.UpdatedAt = &now
+}
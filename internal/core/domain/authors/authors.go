@@ -0,0 +1,71 @@
+package authors
+
+import (
+	"bookstore_api/tools"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Custom errors for domain rules
+var (
+	ErrInvalidID   = errors.New("invalid ID")
+	ErrInvalidName = errors.New("author name must be between 1 and 255 characters")
+)
+
+// Value Objects
+
+type ID int64
+
+func (i ID) Get() int64 {
+	return int64(i)
+}
+
+type Name string
+
+func NewName(value string) (Name, error) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" || utf8.RuneCountInString(value) > 255 {
+		return "", ErrInvalidName
+	}
+	return Name(value), nil
+}
+
+func (n Name) Get() string {
+	return string(n)
+}
+
+type Slug string
+
+func (s Slug) Get() string {
+	return string(s)
+}
+
+// Author Entity - Aggregate Root
+type Author struct {
+	ID   ID
+	Name Name
+	Slug Slug
+}
+
+// NewAuthor Factory Method to create a new author, authors with the same slug are the same person
+func NewAuthor(name string) (*Author, error) {
+	newName, err := NewName(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Author{
+		Name: newName,
+		Slug: Slug(tools.Slugify(newName.Get())),
+	}, nil
+}
+
+func (a *Author) Create(id int64) error {
+	if id < 0 {
+		return ErrInvalidID
+	}
+
+	a.ID = ID(id)
+
+	return nil
+}
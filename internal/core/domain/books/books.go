@@ -1,11 +1,15 @@
 package books
 
 import (
+	"bookstore_api/internal/core/domain/authors"
 	"bookstore_api/tools"
 	"errors"
-	"regexp"
+	"golang.org/x/text/language"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Custom errors for domain rules
@@ -13,8 +17,18 @@ var (
 	ErrInvalidID     = errors.New("invalid ID")
 	ErrNegativePrice = errors.New("price cannot be negative")
 	ErrNegativeStock = errors.New("stock cannot be negative")
+	ErrInvalidSort   = errors.New("sort must be one of price, created_at, title or rating")
+	ErrPriceRange    = errors.New("min price cannot be above max price")
+	ErrDateRange     = errors.New("created after cannot be later than created before")
+	ErrInvalidISBN   = errors.New("isbn must be a valid ISBN-10 or ISBN-13")
+	ErrPublisher     = errors.New("publisher cannot be longer than 255 characters")
+	ErrPageCount     = errors.New("page count cannot be negative")
+	ErrLanguage      = errors.New("language must be a language tag like en or pt-BR")
 )
 
+// PriceBandLimits are where the price facet splits the catalog, the last band has no upper limit
+var PriceBandLimits = []float64{0, 10, 25, 50, 100}
+
 // Value Objects
 
 type ID int64
@@ -77,6 +91,173 @@ func (s Stock) Get() int64 {
 	return s.value
 }
 
+// Rating is aggregated from the reviews of the book, it isn't stored with the book itself
+type Rating struct {
+	average float64
+	count   int64
+}
+
+func NewRating(average float64, count int64) Rating {
+	return Rating{average: average, count: count}
+}
+
+func (r Rating) Average() float64 {
+	return r.average
+}
+
+func (r Rating) Count() int64 {
+	return r.count
+}
+
+// ISBN is kept as the 13 digits of an ISBN-13, ISBN-10s are converted on the way in
+type ISBN string
+
+// NewISBN accepts either form with or without hyphens and spaces, an empty ISBN is a book that has none
+func NewISBN(value string) (ISBN, error) {
+	value = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(value))
+
+	switch len(value) {
+	case 0:
+		return "", nil
+	case 10:
+		sum := 0
+		for i, r := range value {
+			digit := int(r - '0')
+			switch {
+			case r == 'X' && i == 9:
+				digit = 10
+			case r < '0' || r > '9':
+				return "", ErrInvalidISBN
+			}
+			sum += (10 - i) * digit
+		}
+		if sum%11 != 0 {
+			return "", ErrInvalidISBN
+		}
+		// Same digits behind the 978 prefix, only the check digit changes
+		value = "978" + value[:9]
+		return ISBN(value + isbn13CheckDigit(value)), nil
+	case 13:
+		for _, r := range value {
+			if r < '0' || r > '9' {
+				return "", ErrInvalidISBN
+			}
+		}
+		if isbn13CheckDigit(value[:12]) != value[12:] {
+			return "", ErrInvalidISBN
+		}
+		return ISBN(value), nil
+	default:
+		return "", ErrInvalidISBN
+	}
+}
+
+// isbn13CheckDigit weighs the first 12 digits alternately by 1 and 3
+func isbn13CheckDigit(digits string) string {
+	sum := 0
+	for i, r := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(r-'0')
+	}
+	return strconv.Itoa((10 - sum%10) % 10)
+}
+
+func (i ISBN) Get() string {
+	return string(i)
+}
+
+type Publisher string
+
+func NewPublisher(value string) (Publisher, error) {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) > 255 {
+		return "", ErrPublisher
+	}
+	return Publisher(value), nil
+}
+
+func (p Publisher) Get() string {
+	return string(p)
+}
+
+// PageCount of zero means the page count isn't known
+type PageCount int64
+
+func NewPageCount(value int64) (PageCount, error) {
+	if value < 0 {
+		return 0, ErrPageCount
+	}
+	return PageCount(value), nil
+}
+
+func (p PageCount) Get() int64 {
+	return int64(p)
+}
+
+// Language is a BCP 47 tag in its canonical form, pt-br becomes pt-BR
+type Language string
+
+func NewLanguage(value string) (Language, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	tag, err := language.Parse(value)
+	if err != nil {
+		return "", ErrLanguage
+	}
+	return Language(tag.String()), nil
+}
+
+func (l Language) Get() string {
+	return string(l)
+}
+
+// Details are what tells editions of the same title apart, every one of them is optional
+type Details struct {
+	ISBN        ISBN
+	Publisher   Publisher
+	PageCount   PageCount
+	Language    Language
+	PublishedOn *time.Time
+}
+
+// NewDetails Factory Method to create validated details, the publication date is truncated to the day
+func NewDetails(isbn, publisher string, pageCount int64, lang string, publishedOn *time.Time) (Details, error) {
+	newISBN, err := NewISBN(isbn)
+	if err != nil {
+		return Details{}, err
+	}
+	newPublisher, err := NewPublisher(publisher)
+	if err != nil {
+		return Details{}, err
+	}
+	newPageCount, err := NewPageCount(pageCount)
+	if err != nil {
+		return Details{}, err
+	}
+	newLanguage, err := NewLanguage(lang)
+	if err != nil {
+		return Details{}, err
+	}
+
+	details := Details{
+		ISBN:      newISBN,
+		Publisher: newPublisher,
+		PageCount: newPageCount,
+		Language:  newLanguage,
+	}
+	if publishedOn != nil {
+		date := time.Date(publishedOn.Year(), publishedOn.Month(), publishedOn.Day(), 0, 0, 0, 0, time.UTC)
+		details.PublishedOn = &date
+	}
+
+	return details, nil
+}
+
 // Book Entity - Aggregate Root
 type Book struct {
 	ID         ID
@@ -86,10 +267,131 @@ type Book struct {
 	Synopsis   Synopsis
 	Price      Price
 	Stock      Stock
+	Rating     Rating
+	Categories []string
+	Details    Details
+	Authors    []*authors.Author
 	CreatedAt  *time.Time
 	UpdatedAt  *time.Time
 }
 
+type SortField string
+
+const (
+	SortCreatedAt SortField = "created_at"
+	SortPrice     SortField = "price"
+	SortTitle     SortField = "title"
+	SortRating    SortField = "rating"
+)
+
+// NewSortField defaults to the creation date when no sort is asked for
+func NewSortField(value string) (SortField, error) {
+	switch sort := SortField(value); sort {
+	case "":
+		return SortCreatedAt, nil
+	case SortCreatedAt, SortPrice, SortTitle, SortRating:
+		return sort, nil
+	default:
+		return "", ErrInvalidSort
+	}
+}
+
+func (s SortField) Get() string {
+	return string(s)
+}
+
+// Filter narrows the catalog down, zero values don't filter anything
+type Filter struct {
+	MinPrice      *Price
+	MaxPrice      *Price
+	InStock       bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Category      string
+	Author        string
+}
+
+// NewFilter Factory Method to create a filter with consistent ranges
+func NewFilter(minPrice, maxPrice *float64, inStock bool, createdAfter, createdBefore *time.Time, category string) (*Filter, error) {
+	filter := &Filter{
+		InStock:       inStock,
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+		Category:      category,
+	}
+
+	if minPrice != nil {
+		price, err := NewPrice(*minPrice)
+		if err != nil {
+			return nil, err
+		}
+		filter.MinPrice = &price
+	}
+	if maxPrice != nil {
+		price, err := NewPrice(*maxPrice)
+		if err != nil {
+			return nil, err
+		}
+		filter.MaxPrice = &price
+	}
+
+	if filter.MinPrice != nil && filter.MaxPrice != nil && filter.MinPrice.Get() > filter.MaxPrice.Get() {
+		return nil, ErrPriceRange
+	}
+	if createdAfter != nil && createdBefore != nil && createdAfter.After(*createdBefore) {
+		return nil, ErrDateRange
+	}
+
+	return filter, nil
+}
+
+// ListQuery asks for a page of the catalog, the cursor comes from the previous page
+type ListQuery struct {
+	Filter     Filter
+	Sort       SortField
+	Descending bool
+	Cursor     string
+	Limit      int
+}
+
+// Page is a page of the catalog, an empty cursor means there is nothing more that way
+type Page struct {
+	Books      []*Book
+	NextCursor string
+	PrevCursor string
+	Total      int64
+	Facets     Facets
+}
+
+// Facets count the books the other choices of each filter would give, every other filter applied
+type Facets struct {
+	PriceBands []PriceBand
+	InStock    int64
+	Categories []CategoryCount
+}
+
+// PriceBand counts the books priced from Min up to, but not including, Max. A nil Max has no upper limit.
+type PriceBand struct {
+	Min   float64
+	Max   *float64
+	Count int64
+}
+
+type CategoryCount struct {
+	Slug  string
+	Name  string
+	Count int64
+}
+
+// SearchResult is a book matched by a full-text search, along with how well it matched.
+// The highlights are escaped HTML with the matched words wrapped in <mark> tags.
+type SearchResult struct {
+	Book              *Book
+	Rank              float64
+	TitleHighlight    string
+	SynopsisHighlight string
+}
+
 // Whatever, I'm gonna use this for the "BookReq"
 
 // NewBook Factory Method to create a new book
@@ -138,6 +440,31 @@ func (b *Book) UpdateTitle(newTitle string) {
 	b.MarkUpdated()
 }
 
+// UpdateDetails replaces the edition details of the book
+func (b *Book) UpdateDetails(details Details) {
+	b.Details = details
+	b.MarkUpdated()
+}
+
+// SetAuthors replaces the authors of the book in the order they are credited, repeated names are credited once
+func (b *Book) SetAuthors(names []string) error {
+	bookAuthors := make([]*authors.Author, 0, len(names))
+	for _, name := range names {
+		author, err := authors.NewAuthor(name)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(bookAuthors, func(a *authors.Author) bool { return a.Slug == author.Slug }) {
+			continue
+		}
+		bookAuthors = append(bookAuthors, author)
+	}
+
+	b.Authors = bookAuthors
+	b.MarkUpdated()
+	return nil
+}
+
 func (b *Book) UpdateStock(newStock int64) error {
 	stock, err := NewStock(newStock)
 	if err != nil {
@@ -167,21 +494,14 @@ func (b *Book) MarkUpdated() {
 	b.UpdatedAt = &now
 }
 
-// Slug generation logic, encapsulated in a helper function
+// Slug generation logic, shared with the rest of the app through tools.Slugify
 func generateSlug(title string) Slug {
-	s := strings.ToLower(title)
-	s = strings.ReplaceAll(s, " ", "-")
-	re := regexp.MustCompile(`[^\w-]+`)
-	s = re.ReplaceAllString(s, "")
-	re = regexp.MustCompile(`-+`)
-	s = re.ReplaceAllString(s, "-")
-	s = strings.Trim(s, "-")
-	return Slug(s)
-}
-
-// EncryptCover Encryption logic for CoverImage
-func (b *Book) EncryptCover(aesKey []byte) error {
-	encryptedURL, err := tools.Encrypt([]byte(b.CoverImage.Get()), aesKey)
+	return Slug(tools.Slugify(title))
+}
+
+// EncryptCover Encryption logic for CoverImage, always with the current key of the cipher
+func (b *Book) EncryptCover(cipher tools.Cipher) error {
+	encryptedURL, err := cipher.Encrypt([]byte(b.CoverImage.Get()))
 	if err != nil {
 		return err
 	}
@@ -189,9 +509,9 @@ func (b *Book) EncryptCover(aesKey []byte) error {
 	return nil
 }
 
-// DecryptCover Decryption logic for CoverImage
-func (b *Book) DecryptCover(aesKey []byte) error {
-	decryptedURL, err := tools.Decrypt(b.CoverImage.Get(), aesKey)
+// DecryptCover Decryption logic for CoverImage, with whichever key it was encrypted with
+func (b *Book) DecryptCover(cipher tools.Cipher) error {
+	decryptedURL, err := cipher.Decrypt(b.CoverImage.Get())
 	if err != nil {
 		return err
 	}
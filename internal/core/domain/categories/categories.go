@@ -0,0 +1,77 @@
+package categories
+
+import (
+	"bookstore_api/tools"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Custom errors for domain rules
+var (
+	ErrInvalidID   = errors.New("invalid ID")
+	ErrInvalidName = errors.New("category name must be between 1 and 100 characters")
+)
+
+// Value Objects
+
+type ID int64
+
+func (i ID) Get() int64 {
+	return int64(i)
+}
+
+type Name string
+
+func NewName(value string) (Name, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || utf8.RuneCountInString(value) > 100 {
+		return "", ErrInvalidName
+	}
+	return Name(value), nil
+}
+
+func (n Name) Get() string {
+	return string(n)
+}
+
+type Slug string
+
+func (s Slug) Get() string {
+	return string(s)
+}
+
+// Category Entity - Aggregate Root
+type Category struct {
+	ID   ID
+	Name Name
+	Slug Slug
+}
+
+// NewCategory Factory Method to create a new category
+func NewCategory(name string) (*Category, error) {
+	newName, err := NewName(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Category{
+		Name: newName,
+		Slug: Slug(tools.Slugify(newName.Get())),
+	}, nil
+}
+
+// Rename changes the name of the category, the slug follows along
+func (c *Category) Rename(name Name) {
+	c.Name = name
+	c.Slug = Slug(tools.Slugify(name.Get()))
+}
+
+func (c *Category) Create(id int64) error {
+	if id < 0 {
+		return ErrInvalidID
+	}
+
+	c.ID = ID(id)
+
+	return nil
+}
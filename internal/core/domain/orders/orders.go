@@ -0,0 +1,272 @@
+package orders
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// TaxRate is the percentage of the product price charged as tax on every order
+const TaxRate = 0.11
+
+// Custom errors for domain rules
+var (
+	ErrInvalidID            = errors.New("invalid ID")
+	ErrEmptyOrder           = errors.New("order must contain at least one book")
+	ErrInvalidQuantity      = errors.New("quantity must be greater than zero")
+	ErrInvalidPaymentMethod = errors.New("invalid payment method")
+	ErrInvalidAddress       = errors.New("order requires a shipping address")
+	ErrMissingPrice         = errors.New("missing price for book")
+	ErrNegativePrice        = errors.New("price cannot be negative")
+	ErrInvalidStatus        = errors.New("invalid order status")
+	ErrNotCancelable        = errors.New("order can no longer be canceled")
+)
+
+// StockShortage describes a single line item that can't be fulfilled
+type StockShortage struct {
+	BookID    int64 `json:"book_id"`
+	Requested int64 `json:"requested"`
+	Available int64 `json:"available"`
+}
+
+// InsufficientStockError is returned when one or more line items exceed the available stock
+type InsufficientStockError struct {
+	Shortages []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	msg := "insufficient stock"
+	for i, shortage := range e.Shortages {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		msg += fmt.Sprintf("%sbook %d requested %d, available %d", sep, shortage.BookID, shortage.Requested, shortage.Available)
+	}
+	return msg
+}
+
+// Value Objects
+
+type ID int64
+
+func (i ID) Get() int64 {
+	return int64(i)
+}
+
+type Quantity struct {
+	value int64
+}
+
+func NewQuantity(value int64) (Quantity, error) {
+	if value <= 0 {
+		return Quantity{}, ErrInvalidQuantity
+	}
+	return Quantity{value: value}, nil
+}
+
+func (q Quantity) Get() int64 {
+	return q.value
+}
+
+// Amount is a monetary value kept in cents, so the aggregation doesn't drift like floats do
+type Amount struct {
+	cents int64
+}
+
+func NewAmount(value float64) (Amount, error) {
+	if value < 0 {
+		return Amount{}, ErrNegativePrice
+	}
+	return Amount{cents: int64(math.Round(value * 100))}, nil
+}
+
+func (a Amount) Get() float64 {
+	return float64(a.cents) / 100
+}
+
+// PaymentMethod mirrors the PAYMENT_METHOD enum in the database
+type PaymentMethod string
+
+const (
+	PayPal PaymentMethod = "PayPal"
+	Bank   PaymentMethod = "Bank"
+	QRIS   PaymentMethod = "QRIS"
+)
+
+func NewPaymentMethod(value string) (PaymentMethod, error) {
+	for _, method := range []PaymentMethod{PayPal, Bank, QRIS} {
+		if strings.EqualFold(value, string(method)) {
+			return method, nil
+		}
+	}
+	return "", ErrInvalidPaymentMethod
+}
+
+func (pm PaymentMethod) Get() string {
+	return string(pm)
+}
+
+// Status mirrors the ORDER_STATUS enum in the database
+type Status string
+
+const (
+	Placed   Status = "placed"
+	Paid     Status = "paid"
+	Canceled Status = "canceled"
+)
+
+func NewStatus(value string) (Status, error) {
+	switch Status(value) {
+	case Placed, Paid, Canceled:
+		return Status(value), nil
+	}
+	return "", ErrInvalidStatus
+}
+
+func (s Status) Get() string {
+	return string(s)
+}
+
+// Line is a single book inside an order, maps to the OrderBooks table
+type Line struct {
+	ID        ID
+	BookID    int64
+	Quantity  Quantity
+	UnitPrice Amount
+}
+
+// Subtotal is the unit price multiplied by the quantity
+func (l *Line) Subtotal() Amount {
+	return Amount{cents: l.UnitPrice.cents * l.Quantity.Get()}
+}
+
+// Order Entity - Aggregate Root
+type Order struct {
+	ID            ID
+	UserID        int64
+	Address       *addresses.Address
+	Lines         []*Line
+	ProductPrice  Amount
+	TaxFee        Amount
+	TotalPrice    Amount
+	PaymentMethod PaymentMethod
+	Status        Status
+	CreatedAt     *time.Time
+	UpdatedAt     *time.Time
+}
+
+// NewOrder Factory Method to create a new, not yet priced, order
+func NewOrder(userID int64, address *addresses.Address, paymentMethod string) (*Order, error) {
+	if userID <= 0 {
+		return nil, ErrInvalidID
+	}
+	if address == nil {
+		return nil, ErrInvalidAddress
+	}
+	method, err := NewPaymentMethod(paymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	return &Order{
+		UserID:        userID,
+		Address:       address,
+		PaymentMethod: method,
+		Status:        Placed,
+	}, nil
+}
+
+// AddLine adds a book to the order, merging the quantity if the book is already there
+func (o *Order) AddLine(bookID int64, quantity int64) error {
+	if bookID <= 0 {
+		return ErrInvalidID
+	}
+	q, err := NewQuantity(quantity)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range o.Lines {
+		if line.BookID == bookID {
+			line.Quantity = Quantity{value: line.Quantity.Get() + q.Get()}
+			return nil
+		}
+	}
+
+	o.Lines = append(o.Lines, &Line{BookID: bookID, Quantity: q})
+	return nil
+}
+
+// BookIDs returns the ids of every book inside the order
+func (o *Order) BookIDs() []int64 {
+	ids := make([]int64, 0, len(o.Lines))
+	for _, line := range o.Lines {
+		ids = append(ids, line.BookID)
+	}
+	return ids
+}
+
+// Price calculates product_price, tax_fee and total_price off the current book prices.
+// product_price is the aggregation of book price * quantity, tax_fee is TaxRate of that, total is both.
+func (o *Order) Price(prices map[int64]float64) error {
+	if len(o.Lines) == 0 {
+		return ErrEmptyOrder
+	}
+
+	var product int64
+	for _, line := range o.Lines {
+		price, ok := prices[line.BookID]
+		if !ok {
+			return ErrMissingPrice
+		}
+		unitPrice, err := NewAmount(price)
+		if err != nil {
+			return err
+		}
+		line.UnitPrice = unitPrice
+		product += line.Subtotal().cents
+	}
+
+	tax := int64(math.Round(float64(product) * TaxRate))
+
+	o.ProductPrice = Amount{cents: product}
+	o.TaxFee = Amount{cents: tax}
+	o.TotalPrice = Amount{cents: product + tax}
+	return nil
+}
+
+// Cancel moves a placed order to canceled, the reserved stock has to be released by the caller
+func (o *Order) Cancel() error {
+	if o.Status != Placed {
+		return ErrNotCancelable
+	}
+	o.Status = Canceled
+	o.MarkUpdated()
+	return nil
+}
+
+func (o *Order) Create(id int64) error {
+	if id < 0 {
+		return ErrInvalidID
+	}
+
+	o.ID = ID(id)
+
+	if o.CreatedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	o.CreatedAt = &now
+
+	return nil
+}
+
+// MarkUpdated Helper method to mark when the entity is updated
+func (o *Order) MarkUpdated() {
+	now := time.Now().UTC()
+	o.UpdatedAt = &now
+}
@@ -0,0 +1,159 @@
+package payments
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"errors"
+	"time"
+)
+
+// Custom errors for domain rules
+var (
+	ErrInvalidID         = errors.New("invalid ID")
+	ErrInvalidStatus     = errors.New("invalid payment status")
+	ErrInvalidTransition = errors.New("payment is no longer pending")
+	ErrOrderNotPayable   = errors.New("order can't be paid")
+	ErrInvalidEvent      = errors.New("event id, reference and status are required")
+)
+
+// Value Objects
+
+type ID int64
+
+func (i ID) Get() int64 {
+	return int64(i)
+}
+
+// Status mirrors the PAYMENT_STATUS enum in the database
+type Status string
+
+const (
+	Pending   Status = "pending"
+	Completed Status = "completed"
+	Canceled  Status = "canceled"
+)
+
+func NewStatus(value string) (Status, error) {
+	switch Status(value) {
+	case Pending, Completed, Canceled:
+		return Status(value), nil
+	}
+	return "", ErrInvalidStatus
+}
+
+func (s Status) Get() string {
+	return string(s)
+}
+
+// Payment Entity - Aggregate Root, maps to the PaymentResults table
+type Payment struct {
+	ID                ID
+	OrderID           int64
+	UserID            int64
+	Method            orders.PaymentMethod
+	Amount            orders.Amount
+	Status            Status
+	EmailAddress      string
+	ProviderReference string
+	CreatedAt         *time.Time
+	UpdatedAt         *time.Time
+	CompletedAt       *time.Time
+	CanceledAt        *time.Time
+}
+
+// NewPayment Factory Method to create a pending payment for an order
+func NewPayment(order *orders.Order, emailAddress string) (*Payment, error) {
+	if order == nil || order.Status != orders.Placed {
+		return nil, ErrOrderNotPayable
+	}
+	return &Payment{
+		OrderID:      order.ID.Get(),
+		UserID:       order.UserID,
+		Method:       order.PaymentMethod,
+		Amount:       order.TotalPrice,
+		Status:       Pending,
+		EmailAddress: emailAddress,
+	}, nil
+}
+
+// Complete moves a pending payment to completed, a payment is never reopened
+func (p *Payment) Complete() error {
+	if p.Status != Pending {
+		return ErrInvalidTransition
+	}
+	now := time.Now().UTC()
+	p.Status = Completed
+	p.CompletedAt = &now
+	p.UpdatedAt = &now
+	return nil
+}
+
+// Cancel moves a pending payment to canceled, a payment is never reopened
+func (p *Payment) Cancel() error {
+	if p.Status != Pending {
+		return ErrInvalidTransition
+	}
+	now := time.Now().UTC()
+	p.Status = Canceled
+	p.CanceledAt = &now
+	p.UpdatedAt = &now
+	return nil
+}
+
+// Apply moves the payment to the status reported by the provider.
+// Only a pending payment moves, so replayed or out-of-order events can never reopen a settled payment.
+// It reports whether the payment changed.
+func (p *Payment) Apply(status Status) (bool, error) {
+	if p.Status != Pending || status == Pending {
+		return false, nil
+	}
+
+	switch status {
+	case Completed:
+		return true, p.Complete()
+	case Canceled:
+		return true, p.Cancel()
+	}
+
+	return false, ErrInvalidStatus
+}
+
+func (p *Payment) Create(id int64) error {
+	if id < 0 {
+		return ErrInvalidID
+	}
+
+	p.ID = ID(id)
+
+	if p.CreatedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	p.CreatedAt = &now
+
+	return nil
+}
+
+// Event is a payment outcome reported by a provider through its webhook
+type Event struct {
+	ID        string
+	Provider  orders.PaymentMethod
+	Reference string
+	Status    Status
+}
+
+func NewEvent(id string, provider orders.PaymentMethod, reference string, status string) (*Event, error) {
+	if id == "" || reference == "" {
+		return nil, ErrInvalidEvent
+	}
+	newStatus, err := NewStatus(status)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		ID:        id,
+		Provider:  provider,
+		Reference: reference,
+		Status:    newStatus,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package reviews
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxCommentLength matches the VARCHAR(500) of the comment column
+const MaxCommentLength = 500
+
+// Custom errors for domain rules
+var (
+	ErrInvalidID     = errors.New("invalid ID")
+	ErrInvalidRating = errors.New("rating must be between 1 and 5")
+	ErrLongComment   = errors.New("comment cannot be longer than 500 characters")
+)
+
+// Value Objects
+
+type ID int64
+
+func (i ID) Get() int64 {
+	return int64(i)
+}
+
+type Rating struct {
+	value int
+}
+
+func NewRating(value int) (Rating, error) {
+	if value < 1 || value > 5 {
+		return Rating{}, ErrInvalidRating
+	}
+	return Rating{value: value}, nil
+}
+
+func (r Rating) Get() int {
+	return r.value
+}
+
+type Comment string
+
+func NewComment(value string) (Comment, error) {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) > MaxCommentLength {
+		return "", ErrLongComment
+	}
+	return Comment(value), nil
+}
+
+func (c Comment) Get() string {
+	return string(c)
+}
+
+// Review Entity - Aggregate Root
+type Review struct {
+	ID        ID
+	UserID    int64
+	BookID    int64
+	Name      string
+	Rating    Rating
+	Comment   Comment
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// NewReview Factory Method to create a new review
+func NewReview(rating int, comment string) (*Review, error) {
+	newRating, err := NewRating(rating)
+	if err != nil {
+		return nil, err
+	}
+	newComment, err := NewComment(comment)
+	if err != nil {
+		return nil, err
+	}
+	return &Review{
+		Rating:  newRating,
+		Comment: newComment,
+	}, nil
+}
+
+// Edit replaces the rating and comment with the ones of another review
+func (r *Review) Edit(other *Review) {
+	r.Rating = other.Rating
+	r.Comment = other.Comment
+	r.MarkUpdated()
+}
+
+func (r *Review) Create(id int64) error {
+	if id < 0 {
+		return ErrInvalidID
+	}
+
+	r.ID = ID(id)
+
+	if r.CreatedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	r.CreatedAt = &now
+
+	return nil
+}
+
+// MarkUpdated Helper method to mark when the entity is updated
+func (r *Review) MarkUpdated() {
+	now := time.Now().UTC()
+	r.UpdatedAt = &now
+}
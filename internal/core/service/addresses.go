@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"bookstore_api/internal/port"
+	"bookstore_api/tools"
+	"context"
+	"errors"
+)
+
+var (
+	ErrAddressNotFound = errors.New("address not found")
+)
+
+type AddressService struct {
+	addressRepo port.AddressRepository
+}
+
+func NewAddressService(addressRepo port.AddressRepository) *AddressService {
+	return &AddressService{
+		addressRepo: addressRepo,
+	}
+}
+
+func (s *AddressService) GetAllAddresses(ctx context.Context) ([]*addresses.Address, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.addressRepo.GetAllByUserId(ctx, principal.UserID)
+}
+
+// CreateAddress adds the address to the user's address book, the first one is the default right away
+func (s *AddressService) CreateAddress(ctx context.Context, address *addresses.Address) (*addresses.Address, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.addressRepo.GetAllByUserId(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	address.UserID = principal.UserID
+	if len(existing) == 0 {
+		address.IsDefault = true
+	}
+
+	return s.addressRepo.Create(ctx, address)
+}
+
+func (s *AddressService) UpdateAddress(ctx context.Context, id int64, address *addresses.Address) (*addresses.Address, error) {
+	existing, err := s.GetAddressById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Replace(address)
+
+	return s.addressRepo.Update(ctx, existing)
+}
+
+func (s *AddressService) DeleteAddress(ctx context.Context, id int64) error {
+	_, err := s.GetAddressById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.addressRepo.Delete(ctx, id)
+}
+
+func (s *AddressService) SetDefaultAddress(ctx context.Context, id int64) (*addresses.Address, error) {
+	address, err := s.GetAddressById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addressRepo.SetDefault(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+// GetAddressById returns the address only if it is in the user's address book
+func (s *AddressService) GetAddressById(ctx context.Context, id int64) (*addresses.Address, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := s.addressRepo.GetById(ctx, id)
+	if err != nil || address.UserID != principal.UserID {
+		return nil, ErrAddressNotFound
+	}
+
+	return address, nil
+}
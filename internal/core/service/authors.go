@@ -0,0 +1,39 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/authors"
+	"bookstore_api/internal/core/domain/books"
+	"bookstore_api/internal/port"
+	"context"
+	"errors"
+)
+
+var ErrAuthorNotFound = errors.New("author not found")
+
+type AuthorService struct {
+	authorRepo  port.AuthorRepository
+	bookService *BookService
+}
+
+func NewAuthorService(authorRepo port.AuthorRepository, bookService *BookService) *AuthorService {
+	return &AuthorService{
+		authorRepo:  authorRepo,
+		bookService: bookService,
+	}
+}
+
+// GetAuthorPage returns the author and a page of the books they are credited on
+func (s *AuthorService) GetAuthorPage(ctx context.Context, slug string, query *books.ListQuery) (*authors.Author, *books.Page, error) {
+	author, err := s.authorRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, nil, ErrAuthorNotFound
+	}
+
+	query.Filter.Author = author.Slug.Get()
+	page, err := s.bookService.GetAllBooks(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return author, page, nil
+}
@@ -3,41 +3,72 @@ package service
 import (
 	"bookstore_api/internal/core/domain/books"
 	"bookstore_api/internal/port"
+	"bookstore_api/tools"
 	"context"
-	"encoding/base64"
+	"database/sql"
 	"errors"
+	"log"
 	"os"
+	"strconv"
 )
 
 var (
-	KeyError      = errors.New("aes key is not set")
-	DecodingError = errors.New("failed to decode")
+	PageSizeError = errors.New("books max page size must be a positive number")
+)
+
+const (
+	defaultPageSize = 20
+	defaultMaxPage  = 100
+
+	defaultONIXSender   = "bookstore"
+	defaultONIXCurrency = "USD"
 )
 
 type BookService struct {
-	aesKey   []byte
-	bookRepo port.BookRepository
+	cipher      tools.Cipher
+	maxPageSize int
+	bookRepo    port.BookRepository
+
+	onixSender   string
+	onixCurrency string
 }
 
 func NewBookService(bookRepo port.BookRepository) (*BookService, error) {
-	encodedKey := os.Getenv("AES_KEY")
-	if encodedKey == "" {
-		return nil, KeyError
+	cipher, err := tools.CipherFromEnv()
+	if err != nil {
+		return nil, err
 	}
 
-	key, err := base64.StdEncoding.DecodeString(encodedKey)
-	if err != nil {
-		return nil, DecodingError
+	// The cap on the page size is optional, clients asking for more than it get capped rather than refused
+	maxPageSize := defaultMaxPage
+	if value := os.Getenv("BOOKS_MAX_PAGE_SIZE"); value != "" {
+		maxPageSize, err = strconv.Atoi(value)
+		if err != nil || maxPageSize <= 0 {
+			return nil, PageSizeError
+		}
+	}
+
+	// Who the ONIX feed says it comes from, and what its prices are in
+	onixSender := defaultONIXSender
+	if value := os.Getenv("ONIX_SENDER_NAME"); value != "" {
+		onixSender = value
+	}
+	onixCurrency := defaultONIXCurrency
+	if value := os.Getenv("ONIX_CURRENCY"); value != "" {
+		onixCurrency = value
 	}
 
 	return &BookService{
-		aesKey:   key,
-		bookRepo: bookRepo,
+		cipher:       cipher,
+		maxPageSize:  maxPageSize,
+		bookRepo:     bookRepo,
+		onixSender:   onixSender,
+		onixCurrency: onixCurrency,
 	}, nil
 }
 
 func (s *BookService) CreateBook(ctx context.Context, book *books.Book) (*books.Book, error) {
-	err := book.EncryptCover(s.aesKey)
+	err := book.EncryptCover(s.cipher)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +82,7 @@ func (s *BookService) GetBookById(ctx context.Context, id int64) (*books.Book, e
 		return nil, err
 	}
 
-	err = book.DecryptCover(s.aesKey)
+	err = book.DecryptCover(s.cipher)
 	if err != nil {
 		return nil, err
 	}
@@ -59,20 +90,66 @@ func (s *BookService) GetBookById(ctx context.Context, id int64) (*books.Book, e
 	return book, nil
 }
 
-func (s *BookService) GetAllBooks(ctx context.Context, page int64) ([]*books.Book, error) {
-	allBook, err := s.bookRepo.GetAll(ctx, page)
+// GetBookBySlug returns the book with the slug. When the slug belonged to a renamed book,
+// the current slug is returned instead so the caller can redirect to it.
+func (s *BookService) GetBookBySlug(ctx context.Context, slug string) (*books.Book, string, error) {
+	// Only a slug that isn't current is looked up in the history, a failing database must not pass for a missing book
+	book, err := s.bookRepo.GetBySlug(ctx, slug)
+	if errors.Is(err, sql.ErrNoRows) {
+		currentSlug, err := s.bookRepo.GetCurrentSlug(ctx, slug)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", ErrBookNotFound
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return nil, currentSlug, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = book.DecryptCover(s.cipher)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return book, "", nil
+}
+
+// GetAllBooks lists a page of the catalog matching the filter of the query
+func (s *BookService) GetAllBooks(ctx context.Context, query *books.ListQuery) (*books.Page, error) {
+	switch {
+	case query.Limit <= 0:
+		query.Limit = defaultPageSize
+	case query.Limit > s.maxPageSize:
+		query.Limit = s.maxPageSize
+	}
+
+	page, err := s.bookRepo.GetAll(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, book := range page.Books {
+		s.decryptListedCover(book)
+	}
+
+	return page, nil
+}
+
+// SearchBooks runs a full-text search over the catalog
+func (s *BookService) SearchBooks(ctx context.Context, query string, page int64) ([]*books.SearchResult, error) {
+	results, err := s.bookRepo.Search(ctx, query, page)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, book := range allBook {
-		err = book.DecryptCover(s.aesKey)
-		if err != nil {
-			return nil, err
-		}
+	for _, result := range results {
+		s.decryptListedCover(result.Book)
 	}
 
-	return allBook, nil
+	return results, nil
 }
 
 func (s *BookService) UpdateBook(ctx context.Context, id int64, book *books.Book) (*books.Book, error) {
@@ -83,7 +160,7 @@ func (s *BookService) UpdateBook(ctx context.Context, id int64, book *books.Book
 	}
 
 	// Decrypt the cover image
-	err = existingBook.DecryptCover(s.aesKey)
+	err = existingBook.DecryptCover(s.cipher)
 	if err != nil {
 		return nil, err
 	}
@@ -112,12 +189,38 @@ func (s *BookService) UpdateBook(ctx context.Context, id int64, book *books.Book
 				return nil, err
 			}
 		}
+
+		// Details only change when they are given, like the rest
+		details := existingBook.Details
+		if book.Details.ISBN != "" {
+			details.ISBN = book.Details.ISBN
+		}
+		if book.Details.Publisher != "" {
+			details.Publisher = book.Details.Publisher
+		}
+		if book.Details.PageCount != 0 {
+			details.PageCount = book.Details.PageCount
+		}
+		if book.Details.Language != "" {
+			details.Language = book.Details.Language
+		}
+		if book.Details.PublishedOn != nil {
+			details.PublishedOn = book.Details.PublishedOn
+		}
+		if details != existingBook.Details {
+			existingBook.UpdateDetails(details)
+		}
+
+		// An empty list of authors is still a list, it uncredits everyone
+		if book.Authors != nil {
+			existingBook.Authors = book.Authors
+		}
 	}
 	// Apply the update to the book
 	existingBook.MarkUpdated()
 
 	// Encrypt the updated cover image
-	err = existingBook.EncryptCover(s.aesKey)
+	err = existingBook.EncryptCover(s.cipher)
 	if err != nil {
 		return nil, err
 	}
@@ -129,7 +232,7 @@ func (s *BookService) UpdateBook(ctx context.Context, id int64, book *books.Book
 	}
 
 	// Decrypt the cover image of the updated book before returning
-	err = updatedBook.DecryptCover(s.aesKey)
+	err = updatedBook.DecryptCover(s.cipher)
 	if err != nil {
 		return nil, err
 	}
@@ -137,6 +240,15 @@ func (s *BookService) UpdateBook(ctx context.Context, id int64, book *books.Book
 	return updatedBook, nil
 }
 
+// decryptListedCover keeps one unreadable cover from failing a whole listing, the book is listed without it
+func (s *BookService) decryptListedCover(book *books.Book) {
+	err := book.DecryptCover(s.cipher)
+	if err != nil {
+		log.Printf("error decrypting cover of book %d: %v", book.ID.Get(), err)
+		book.CoverImage = ""
+	}
+}
+
 func (s *BookService) DeleteBook(ctx context.Context, id int64) error {
 	return s.bookRepo.Delete(ctx, id)
 }
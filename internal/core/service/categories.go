@@ -0,0 +1,63 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/categories"
+	"bookstore_api/internal/port"
+	"context"
+	"errors"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+)
+
+type CategoryService struct {
+	categoryRepo port.CategoryRepository
+	bookRepo     port.BookRepository
+}
+
+func NewCategoryService(categoryRepo port.CategoryRepository, bookRepo port.BookRepository) *CategoryService {
+	return &CategoryService{
+		categoryRepo: categoryRepo,
+		bookRepo:     bookRepo,
+	}
+}
+
+func (s *CategoryService) CreateCategory(ctx context.Context, category *categories.Category) (*categories.Category, error) {
+	return s.categoryRepo.Create(ctx, category)
+}
+
+func (s *CategoryService) GetAllCategories(ctx context.Context) ([]*categories.Category, error) {
+	return s.categoryRepo.GetAll(ctx)
+}
+
+func (s *CategoryService) UpdateCategory(ctx context.Context, id int64, category *categories.Category) (*categories.Category, error) {
+	existing, err := s.categoryRepo.GetById(ctx, id)
+	if err != nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	existing.Rename(category.Name)
+
+	return s.categoryRepo.Update(ctx, existing)
+}
+
+func (s *CategoryService) DeleteCategory(ctx context.Context, id int64) error {
+	return s.categoryRepo.Delete(ctx, id)
+}
+
+// AttachCategory puts the book in the category, both have to exist
+func (s *CategoryService) AttachCategory(ctx context.Context, bookID int64, categoryID int64) error {
+	if _, err := s.bookRepo.GetById(ctx, bookID); err != nil {
+		return ErrBookNotFound
+	}
+	if _, err := s.categoryRepo.GetById(ctx, categoryID); err != nil {
+		return ErrCategoryNotFound
+	}
+
+	return s.categoryRepo.AttachBook(ctx, bookID, categoryID)
+}
+
+func (s *CategoryService) DetachCategory(ctx context.Context, bookID int64, categoryID int64) error {
+	return s.categoryRepo.DetachBook(ctx, bookID, categoryID)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"bookstore_api/internal/port"
+	"bookstore_api/tools"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrCoverType       = errors.New("cover must be a JPEG or PNG image")
+	ErrCoverSize       = errors.New("cover cannot be larger than 10 MB")
+	ErrCoverDimensions = errors.New("cover must be between 200x200 and 8000x8000 pixels")
+	ErrCoverNotFound   = errors.New("cover not found")
+)
+
+const (
+	MaxCoverSize = 10 << 20
+
+	minCoverSide = 200
+	maxCoverSide = 8000
+
+	coverQuality = 85
+)
+
+// coverRenditions are the widths covers get scaled down to, the original is kept as uploaded
+var coverRenditions = map[string]int{
+	"thumbnail": 150,
+	"medium":    600,
+}
+
+// coverFilePattern matches the files a stored cover version is made of
+var coverFilePattern = regexp.MustCompile(`^(original\.(jpg|png)|thumbnail\.jpg|medium\.jpg)$`)
+
+// coverVersionPattern matches the content hash a cover is stored under, new content gets a new URL
+var coverVersionPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Cover is where the renditions of an uploaded cover are served from
+type Cover struct {
+	Original  string
+	Medium    string
+	Thumbnail string
+}
+
+type CoverService struct {
+	baseURL     string
+	bookService *BookService
+	storage     port.BlobStorage
+}
+
+func NewCoverService(bookService *BookService, storage port.BlobStorage) *CoverService {
+	// Covers are linked relative to the API unless they are served from somewhere else, like a CDN in front of it
+	baseURL := strings.TrimSuffix(os.Getenv("COVER_BASE_URL"), "/")
+
+	return &CoverService{
+		baseURL:     baseURL,
+		bookService: bookService,
+		storage:     storage,
+	}
+}
+
+func coverKey(bookID int64, version string, file string) string {
+	return fmt.Sprintf("covers/%d/%s/%s", bookID, version, file)
+}
+
+func (s *CoverService) coverURL(bookID int64, version string, file string) string {
+	return s.baseURL + "/" + coverKey(bookID, version, file)
+}
+
+// UploadCover stores the image with its renditions and makes it the cover of the book, the previous upload is removed
+func (s *CoverService) UploadCover(ctx context.Context, bookID int64, upload io.Reader) (*books.Book, *Cover, error) {
+	data, err := io.ReadAll(io.LimitReader(upload, MaxCoverSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > MaxCoverSize {
+		return nil, nil, ErrCoverSize
+	}
+
+	// The content decides the type, not whatever the client claims it is
+	var extension string
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		extension = "jpg"
+	case "image/png":
+		extension = "png"
+	default:
+		return nil, nil, ErrCoverType
+	}
+
+	// The header is enough to refuse an oversized image before decoding all of it
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, ErrCoverType
+	}
+	if config.Width < minCoverSide || config.Height < minCoverSide || config.Width > maxCoverSide || config.Height > maxCoverSide {
+		return nil, nil, ErrCoverDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, ErrCoverType
+	}
+
+	book, err := s.bookService.GetBookById(ctx, bookID)
+	if err != nil {
+		return nil, nil, ErrBookNotFound
+	}
+	previousCover := book.CoverImage.Get()
+
+	sum := sha256.Sum256(data)
+	version := hex.EncodeToString(sum[:8])
+	original := "original." + extension
+
+	stored := []string{coverKey(bookID, version, original)}
+	err = s.storage.Put(ctx, stored[0], bytes.NewReader(data), "image/"+strings.Replace(extension, "jpg", "jpeg", 1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error storing cover: %v", err)
+	}
+
+	// Every rendition is scaled from the same flattened copy
+	flat := tools.Flatten(img)
+	for name, width := range coverRenditions {
+		rendition := &bytes.Buffer{}
+		err = jpeg.Encode(rendition, tools.ResizeToWidth(flat, width), &jpeg.Options{Quality: coverQuality})
+		if err != nil {
+			s.removeBlobs(ctx, stored)
+			return nil, nil, err
+		}
+
+		key := coverKey(bookID, version, name+".jpg")
+		err = s.storage.Put(ctx, key, rendition, "image/jpeg")
+		if err != nil {
+			s.removeBlobs(ctx, stored)
+			return nil, nil, fmt.Errorf("error storing cover: %v", err)
+		}
+		stored = append(stored, key)
+	}
+
+	cover := &Cover{
+		Original:  s.coverURL(bookID, version, original),
+		Medium:    s.coverURL(bookID, version, "medium.jpg"),
+		Thumbnail: s.coverURL(bookID, version, "thumbnail.jpg"),
+	}
+
+	updatedBook, err := s.bookService.UpdateBook(ctx, bookID, &books.Book{CoverImage: books.CoverImage(cover.Original)})
+	if err != nil {
+		s.removeBlobs(ctx, stored)
+		return nil, nil, err
+	}
+
+	// Uploading the same image again lands on the same version, which must stay
+	if previousVersion, ok := s.storedVersion(bookID, previousCover); ok && previousVersion != version {
+		s.removeBlobs(ctx, []string{
+			coverKey(bookID, previousVersion, "original.jpg"),
+			coverKey(bookID, previousVersion, "original.png"),
+			coverKey(bookID, previousVersion, "medium.jpg"),
+			coverKey(bookID, previousVersion, "thumbnail.jpg"),
+		})
+	}
+
+	return updatedBook, cover, nil
+}
+
+// storedVersion finds the version of a cover URL this service handed out, covers linked from elsewhere have none
+func (s *CoverService) storedVersion(bookID int64, coverURL string) (string, bool) {
+	prefix := fmt.Sprintf("%s/covers/%d/", s.baseURL, bookID)
+	if !strings.HasPrefix(coverURL, prefix) {
+		return "", false
+	}
+
+	version, _, _ := strings.Cut(strings.TrimPrefix(coverURL, prefix), "/")
+	return version, coverVersionPattern.MatchString(version)
+}
+
+// removeBlobs cleans up on a best effort basis, a leftover file costs disk space but breaks nothing
+func (s *CoverService) removeBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		_ = s.storage.Delete(ctx, key)
+	}
+}
+
+// OpenCover hands back a stored cover file to be closed by the caller
+func (s *CoverService) OpenCover(ctx context.Context, bookID int64, version string, file string) (io.ReadCloser, *port.BlobInfo, error) {
+	if !coverVersionPattern.MatchString(version) || !coverFilePattern.MatchString(file) {
+		return nil, nil, ErrCoverNotFound
+	}
+
+	blob, info, err := s.storage.Get(ctx, coverKey(bookID, version, file))
+	if err != nil {
+		return nil, nil, ErrCoverNotFound
+	}
+
+	return blob, info, nil
+}
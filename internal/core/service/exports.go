@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrExportFormat = errors.New("export format must be csv, jsonl or onix")
+
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"
+	ExportJSONL ExportFormat = "jsonl"
+	ExportONIX  ExportFormat = "onix"
+)
+
+func NewExportFormat(format string) (ExportFormat, error) {
+	switch f := ExportFormat(strings.ToLower(strings.TrimPrefix(format, "."))); f {
+	case ExportCSV, ExportJSONL, ExportONIX:
+		return f, nil
+	case "ndjson":
+		return ExportJSONL, nil
+	case "xml":
+		return ExportONIX, nil
+	default:
+		return "", ErrExportFormat
+	}
+}
+
+// ContentType is the media type a file of the format is served as
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportJSONL:
+		return "application/jsonl; charset=utf-8"
+	default:
+		return "application/xml; charset=utf-8"
+	}
+}
+
+// Extension is the file extension of the format, the ONIX feed is plain XML
+func (f ExportFormat) Extension() string {
+	if f == ExportONIX {
+		return "xml"
+	}
+	return string(f)
+}
+
+// exportColumns are read back by the import, so an export can be edited and imported again
+var exportColumns = []string{
+	"id", "title", "slug", "cover_image", "synopsis", "price", "stock",
+	"isbn", "authors", "publisher", "page_count", "language", "published_on",
+	"rating", "num_reviews", "categories", "created_at", "updated_at",
+}
+
+type exportRow struct {
+	ID         int64   `json:"id"`
+	Title      string  `json:"title"`
+	Slug       string  `json:"slug"`
+	CoverImage string  `json:"cover_image"`
+	Synopsis   string  `json:"synopsis"`
+	Price      float64 `json:"price"`
+	Stock      int64   `json:"stock"`
+
+	ISBN        string   `json:"isbn"`
+	Authors     []string `json:"authors"`
+	Publisher   string   `json:"publisher"`
+	PageCount   int64    `json:"page_count"`
+	Language    string   `json:"language"`
+	PublishedOn string   `json:"published_on"`
+
+	Rating     float64    `json:"rating"`
+	NumReviews int64      `json:"num_reviews"`
+	Categories []string   `json:"categories"`
+	CreatedAt  *time.Time `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+func newExportRow(book *books.Book) *exportRow {
+	categories := book.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+
+	authorNames := make([]string, 0, len(book.Authors))
+	for _, author := range book.Authors {
+		authorNames = append(authorNames, author.Name.Get())
+	}
+
+	var publishedOn string
+	if book.Details.PublishedOn != nil {
+		publishedOn = book.Details.PublishedOn.Format(time.DateOnly)
+	}
+
+	return &exportRow{
+		ID:         book.ID.Get(),
+		Title:      book.Title.Get(),
+		Slug:       book.Slug.Get(),
+		CoverImage: book.CoverImage.Get(),
+		Synopsis:   book.Synopsis.Get(),
+		Price:      book.Price.Get(),
+		Stock:      book.Stock.Get(),
+
+		ISBN:        book.Details.ISBN.Get(),
+		Authors:     authorNames,
+		Publisher:   book.Details.Publisher.Get(),
+		PageCount:   book.Details.PageCount.Get(),
+		Language:    book.Details.Language.Get(),
+		PublishedOn: publishedOn,
+
+		Rating:     book.Rating.Average(),
+		NumReviews: book.Rating.Count(),
+		Categories: categories,
+		CreatedAt:  book.CreatedAt,
+		UpdatedAt:  book.UpdatedAt,
+	}
+}
+
+func (row *exportRow) record() []string {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	// A page count that isn't known is left empty rather than written as 0
+	var pageCount string
+	if row.PageCount > 0 {
+		pageCount = strconv.FormatInt(row.PageCount, 10)
+	}
+
+	return []string{
+		strconv.FormatInt(row.ID, 10),
+		row.Title,
+		row.Slug,
+		row.CoverImage,
+		row.Synopsis,
+		strconv.FormatFloat(row.Price, 'f', 2, 64),
+		strconv.FormatInt(row.Stock, 10),
+		row.ISBN,
+		strings.Join(row.Authors, importAuthorSeparator),
+		row.Publisher,
+		pageCount,
+		row.Language,
+		row.PublishedOn,
+		strconv.FormatFloat(row.Rating, 'f', 2, 64),
+		strconv.FormatInt(row.NumReviews, 10),
+		strings.Join(row.Categories, "|"),
+		formatTime(row.CreatedAt),
+		formatTime(row.UpdatedAt),
+	}
+}
+
+// ExportBooks writes the whole catalog to w as it is read, the covers are decrypted on the way out
+func (s *BookService) ExportBooks(ctx context.Context, w io.Writer, format ExportFormat) error {
+	switch format {
+	case ExportCSV:
+		writer := csv.NewWriter(w)
+		err := writer.Write(exportColumns)
+		if err != nil {
+			return err
+		}
+
+		err = s.streamBooks(ctx, func(book *books.Book) error {
+			return writer.Write(newExportRow(book).record())
+		})
+		if err != nil {
+			return err
+		}
+
+		writer.Flush()
+		return writer.Error()
+
+	case ExportJSONL:
+		encoder := json.NewEncoder(w)
+		return s.streamBooks(ctx, func(book *books.Book) error {
+			return encoder.Encode(newExportRow(book))
+		})
+
+	case ExportONIX:
+		return s.exportONIX(ctx, w)
+
+	default:
+		return ErrExportFormat
+	}
+}
+
+func (s *BookService) streamBooks(ctx context.Context, fn func(book *books.Book) error) error {
+	return s.bookRepo.Stream(ctx, func(book *books.Book) error {
+		s.decryptListedCover(book)
+		return fn(book)
+	})
+}
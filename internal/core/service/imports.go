@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrImportFormat = errors.New("import format must be csv or jsonl")
+	ErrImportHeader = errors.New("csv header must name the title, cover_image, synopsis, price and stock columns")
+	ErrImportDate   = errors.New("published_on must be a date like 2006-01-02")
+	ErrMissingTitle = errors.New("title is required")
+)
+
+type ImportFormat string
+
+const (
+	ImportCSV   ImportFormat = "csv"
+	ImportJSONL ImportFormat = "jsonl"
+)
+
+func NewImportFormat(format string) (ImportFormat, error) {
+	switch f := ImportFormat(strings.ToLower(strings.TrimPrefix(format, "."))); f {
+	case ImportCSV, ImportJSONL:
+		return f, nil
+	case "ndjson":
+		return ImportJSONL, nil
+	default:
+		return "", ErrImportFormat
+	}
+}
+
+type ImportStatus string
+
+const (
+	ImportCreated  ImportStatus = "created"
+	ImportUpdated  ImportStatus = "updated"
+	ImportRejected ImportStatus = "rejected"
+)
+
+// ImportLine is the outcome of a single line of the file, Line counts from 1 and includes the csv header
+type ImportLine struct {
+	Line   int
+	Status ImportStatus
+	BookID int64
+	Title  string
+	Err    error
+}
+
+type ImportReport struct {
+	Created  int
+	Updated  int
+	Rejected int
+	Lines    []*ImportLine
+}
+
+func (r *ImportReport) add(line *ImportLine) {
+	switch line.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportRejected:
+		r.Rejected++
+	}
+	r.Lines = append(r.Lines, line)
+}
+
+// importRow is a book as the feed describes it, numbers are kept as text so a bad one only rejects its own row
+type importRow struct {
+	Title      string      `json:"title"`
+	CoverImage string      `json:"cover_image"`
+	Synopsis   string      `json:"synopsis"`
+	Price      json.Number `json:"price"`
+	Stock      json.Number `json:"stock"`
+
+	ISBN        string      `json:"isbn"`
+	Authors     []string    `json:"authors"`
+	Publisher   string      `json:"publisher"`
+	PageCount   json.Number `json:"page_count"`
+	Language    string      `json:"language"`
+	PublishedOn string      `json:"published_on"`
+}
+
+// importAuthorSeparator splits the authors of a csv row, the same way the export joins them
+const importAuthorSeparator = "|"
+
+func (row *importRow) newBook() (*books.Book, error) {
+	title := strings.TrimSpace(row.Title)
+	if title == "" {
+		return nil, ErrMissingTitle
+	}
+
+	price, err := strconv.ParseFloat(strings.TrimSpace(row.Price.String()), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price %q", row.Price)
+	}
+
+	// Feeds often leave the stock out of titles that aren't on hand yet
+	var stock int64
+	if value := strings.TrimSpace(row.Stock.String()); value != "" {
+		stock, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stock %q", row.Stock)
+		}
+	}
+
+	book, err := books.NewBook(title, strings.TrimSpace(row.CoverImage), strings.TrimSpace(row.Synopsis), price, stock)
+	if err != nil {
+		return nil, err
+	}
+
+	var pageCount int64
+	if value := strings.TrimSpace(row.PageCount.String()); value != "" {
+		pageCount, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page count %q", row.PageCount)
+		}
+	}
+
+	var publishedOn *time.Time
+	if value := strings.TrimSpace(row.PublishedOn); value != "" {
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, ErrImportDate
+		}
+		publishedOn = &date
+	}
+
+	book.Details, err = books.NewDetails(row.ISBN, row.Publisher, pageCount, row.Language, publishedOn)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(row.Authors) > 0 {
+		err = book.SetAuthors(row.Authors)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return book, nil
+}
+
+// ImportBooks upserts every book of the feed by ISBN, or by title when it has none. A row that fails is rejected on its own,
+// the error is only returned when the feed itself can't be read. Every row is saved as it is read, so a feed that breaks off
+// halfway returns the report of the lines before along with the error.
+func (s *BookService) ImportBooks(ctx context.Context, feed io.Reader, format ImportFormat) (*ImportReport, error) {
+	report := &ImportReport{}
+
+	upsertRow := func(line int, row *importRow) {
+		result := &ImportLine{Line: line, Title: row.Title}
+		defer report.add(result)
+
+		book, err := row.newBook()
+		if err != nil {
+			result.Status, result.Err = ImportRejected, err
+			return
+		}
+
+		err = book.EncryptCover(s.cipher)
+		if err != nil {
+			result.Status, result.Err = ImportRejected, err
+			return
+		}
+
+		saved, created, err := s.bookRepo.Upsert(ctx, book)
+		if err != nil {
+			result.Status, result.Err = ImportRejected, err
+			return
+		}
+
+		result.BookID = saved.ID.Get()
+		result.Status = ImportUpdated
+		if created {
+			result.Status = ImportCreated
+		}
+	}
+
+	reject := func(line int, err error) {
+		report.add(&ImportLine{Line: line, Status: ImportRejected, Err: err})
+	}
+
+	switch format {
+	case ImportCSV:
+		reader := csv.NewReader(feed)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+
+		header, err := reader.Read()
+		if err != nil {
+			return nil, ErrImportHeader
+		}
+		columns := make(map[string]int, len(header))
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+		}
+		for _, name := range []string{"title", "cover_image", "synopsis", "price", "stock"} {
+			if _, ok := columns[name]; !ok {
+				return nil, ErrImportHeader
+			}
+		}
+
+		for {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				var parseErr *csv.ParseError
+				if !errors.As(err, &parseErr) {
+					return report, fmt.Errorf("error reading csv: %w", err)
+				}
+				reject(parseErr.StartLine, parseErr.Err)
+				continue
+			}
+			// The field positions only exist for a record that was read without error
+			line, _ := reader.FieldPos(0)
+			if len(record) != len(header) {
+				reject(line, fmt.Errorf("expected %d fields, got %d", len(header), len(record)))
+				continue
+			}
+
+			// The edition details are optional columns
+			field := func(name string) string {
+				if i, ok := columns[name]; ok {
+					return record[i]
+				}
+				return ""
+			}
+
+			var rowAuthors []string
+			if value := strings.TrimSpace(field("authors")); value != "" {
+				rowAuthors = strings.Split(value, importAuthorSeparator)
+			}
+
+			upsertRow(line, &importRow{
+				Title:       field("title"),
+				CoverImage:  field("cover_image"),
+				Synopsis:    field("synopsis"),
+				Price:       json.Number(field("price")),
+				Stock:       json.Number(field("stock")),
+				ISBN:        field("isbn"),
+				Authors:     rowAuthors,
+				Publisher:   field("publisher"),
+				PageCount:   json.Number(field("page_count")),
+				Language:    field("language"),
+				PublishedOn: field("published_on"),
+			})
+		}
+
+	case ImportJSONL:
+		scanner := bufio.NewScanner(feed)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+		for line := 1; scanner.Scan(); line++ {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+
+			row := &importRow{}
+			decoder := json.NewDecoder(strings.NewReader(text))
+			decoder.UseNumber()
+			if err := decoder.Decode(row); err != nil {
+				reject(line, fmt.Errorf("invalid json: %v", err))
+				continue
+			}
+
+			upsertRow(line, row)
+		}
+		if err := scanner.Err(); err != nil {
+			return report, fmt.Errorf("error reading jsonl: %w", err)
+		}
+
+	default:
+		return nil, ErrImportFormat
+	}
+
+	return report, nil
+}
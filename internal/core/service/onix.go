@@ -0,0 +1,280 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"context"
+	"encoding/xml"
+	"golang.org/x/text/language"
+	"io"
+	"strconv"
+	"time"
+)
+
+// A simplified ONIX 3.0 product feed, only the blocks our distributors read are filled in.
+// The codes come from the ONIX code lists, noted next to each of them.
+
+type onixHeader struct {
+	XMLName      xml.Name `xml:"Header"`
+	SenderName   string   `xml:"Sender>SenderName"`
+	SentDateTime string   `xml:"SentDateTime"`
+}
+
+type onixProductIdentifier struct {
+	ProductIDType string `xml:"ProductIDType"`
+	IDTypeName    string `xml:"IDTypeName,omitempty"`
+	IDValue       string `xml:"IDValue"`
+}
+
+type onixTitleElement struct {
+	TitleElementLevel string `xml:"TitleElementLevel"`
+	TitleText         string `xml:"TitleText"`
+}
+
+type onixTitleDetail struct {
+	TitleType    string           `xml:"TitleType"`
+	TitleElement onixTitleElement `xml:"TitleElement"`
+}
+
+type onixSubject struct {
+	SubjectSchemeIdentifier string `xml:"SubjectSchemeIdentifier"`
+	SubjectHeadingText      string `xml:"SubjectHeadingText"`
+}
+
+type onixTextContent struct {
+	TextType        string `xml:"TextType"`
+	ContentAudience string `xml:"ContentAudience"`
+	Text            string `xml:"Text"`
+}
+
+type onixSupportingResource struct {
+	ResourceContentType string `xml:"ResourceContentType"`
+	ContentAudience     string `xml:"ContentAudience"`
+	ResourceMode        string `xml:"ResourceMode"`
+	ResourceForm        string `xml:"ResourceVersion>ResourceForm"`
+	ResourceLink        string `xml:"ResourceVersion>ResourceLink"`
+}
+
+type onixContributor struct {
+	SequenceNumber  int    `xml:"SequenceNumber"`
+	ContributorRole string `xml:"ContributorRole"`
+	PersonName      string `xml:"PersonName"`
+}
+
+type onixLanguage struct {
+	LanguageRole string `xml:"LanguageRole"`
+	LanguageCode string `xml:"LanguageCode"`
+}
+
+type onixExtent struct {
+	ExtentType  string `xml:"ExtentType"`
+	ExtentValue int64  `xml:"ExtentValue"`
+	ExtentUnit  string `xml:"ExtentUnit"`
+}
+
+type onixPublisher struct {
+	PublishingRole string `xml:"PublishingRole"`
+	PublisherName  string `xml:"PublisherName"`
+}
+
+type onixDate struct {
+	DateFormat string `xml:"dateformat,attr"`
+	Value      string `xml:",chardata"`
+}
+
+type onixPublishingDate struct {
+	PublishingDateRole string   `xml:"PublishingDateRole"`
+	Date               onixDate `xml:"Date"`
+}
+
+type onixPrice struct {
+	PriceType    string `xml:"PriceType"`
+	PriceAmount  string `xml:"PriceAmount"`
+	CurrencyCode string `xml:"CurrencyCode"`
+}
+
+type onixSupplyDetail struct {
+	SupplierRole        string    `xml:"Supplier>SupplierRole"`
+	SupplierName        string    `xml:"Supplier>SupplierName"`
+	ProductAvailability string    `xml:"ProductAvailability"`
+	OnHand              int64     `xml:"Stock>OnHand"`
+	Price               onixPrice `xml:"Price"`
+}
+
+type onixProduct struct {
+	XMLName            xml.Name                `xml:"Product"`
+	RecordReference    string                  `xml:"RecordReference"`
+	NotificationType   string                  `xml:"NotificationType"`
+	ProductIdentifiers []onixProductIdentifier `xml:"ProductIdentifier"`
+	ProductComposition string                  `xml:"DescriptiveDetail>ProductComposition"`
+	ProductForm        string                  `xml:"DescriptiveDetail>ProductForm"`
+	TitleDetail        onixTitleDetail         `xml:"DescriptiveDetail>TitleDetail"`
+	Contributors       []onixContributor       `xml:"DescriptiveDetail>Contributor"`
+	Language           *onixLanguage           `xml:"DescriptiveDetail>Language,omitempty"`
+	Extent             *onixExtent             `xml:"DescriptiveDetail>Extent,omitempty"`
+	Subjects           []onixSubject           `xml:"DescriptiveDetail>Subject"`
+	TextContent        *onixTextContent        `xml:"CollateralDetail>TextContent,omitempty"`
+	SupportingResource *onixSupportingResource `xml:"CollateralDetail>SupportingResource,omitempty"`
+	Publisher          *onixPublisher          `xml:"PublishingDetail>Publisher,omitempty"`
+	PublishingDate     *onixPublishingDate     `xml:"PublishingDetail>PublishingDate,omitempty"`
+	SupplyDetail       onixSupplyDetail        `xml:"ProductSupply>SupplyDetail"`
+}
+
+func (s *BookService) newONIXProduct(book *books.Book) *onixProduct {
+	// List 65, 21 is available and 31 is out of stock
+	availability := "21"
+	if book.Stock.Get() <= 0 {
+		availability = "31"
+	}
+
+	product := &onixProduct{
+		RecordReference:  s.onixSender + "-" + strconv.FormatInt(book.ID.Get(), 10),
+		NotificationType: "03", // List 1, confirmed record
+		// List 5, 01 is a proprietary identifier, every book has its slug even without an ISBN
+		ProductIdentifiers: []onixProductIdentifier{{ProductIDType: "01", IDTypeName: "Slug", IDValue: book.Slug.Get()}},
+		ProductComposition: "00", // List 2, single-component retail product
+		ProductForm:        "BA", // List 150, book
+		TitleDetail: onixTitleDetail{
+			TitleType:    "01", // List 15, distinctive title
+			TitleElement: onixTitleElement{TitleElementLevel: "01", TitleText: book.Title.Get()},
+		},
+		SupplyDetail: onixSupplyDetail{
+			SupplierRole:        "01", // List 93, publisher to retailers
+			SupplierName:        s.onixSender,
+			ProductAvailability: availability,
+			OnHand:              book.Stock.Get(),
+			Price: onixPrice{
+				PriceType:    "01", // List 58, RRP excluding tax
+				PriceAmount:  strconv.FormatFloat(book.Price.Get(), 'f', 2, 64),
+				CurrencyCode: s.onixCurrency,
+			},
+		},
+	}
+
+	if isbn := book.Details.ISBN.Get(); isbn != "" {
+		// List 5, 15 is an ISBN-13
+		product.ProductIdentifiers = append(product.ProductIdentifiers, onixProductIdentifier{ProductIDType: "15", IDValue: isbn})
+	}
+
+	for i, author := range book.Authors {
+		// List 17, A01 is written by
+		product.Contributors = append(product.Contributors, onixContributor{SequenceNumber: i + 1, ContributorRole: "A01", PersonName: author.Name.Get()})
+	}
+
+	if code := onixLanguageCode(book.Details.Language.Get()); code != "" {
+		// List 22, 01 is the language of the text
+		product.Language = &onixLanguage{LanguageRole: "01", LanguageCode: code}
+	}
+
+	if pageCount := book.Details.PageCount.Get(); pageCount > 0 {
+		// List 23, 00 is the main content page count, List 24, 03 is pages
+		product.Extent = &onixExtent{ExtentType: "00", ExtentValue: pageCount, ExtentUnit: "03"}
+	}
+
+	if publisher := book.Details.Publisher.Get(); publisher != "" {
+		// List 45, 01 is the publisher
+		product.Publisher = &onixPublisher{PublishingRole: "01", PublisherName: publisher}
+	}
+
+	if publishedOn := book.Details.PublishedOn; publishedOn != nil {
+		// List 163, 01 is the publication date, List 55, 00 is YYYYMMDD
+		product.PublishingDate = &onixPublishingDate{
+			PublishingDateRole: "01",
+			Date:               onixDate{DateFormat: "00", Value: publishedOn.Format("20060102")},
+		}
+	}
+
+	for _, category := range book.Categories {
+		// List 26, 20 is keywords
+		product.Subjects = append(product.Subjects, onixSubject{SubjectSchemeIdentifier: "20", SubjectHeadingText: category})
+	}
+
+	if synopsis := book.Synopsis.Get(); synopsis != "" {
+		// List 153, 03 is the description
+		product.TextContent = &onixTextContent{TextType: "03", ContentAudience: "00", Text: synopsis}
+	}
+
+	if cover := book.CoverImage.Get(); cover != "" {
+		// List 158, 01 is the front cover, List 161, 02 is a downloadable file
+		product.SupportingResource = &onixSupportingResource{
+			ResourceContentType: "01",
+			ContentAudience:     "00",
+			ResourceMode:        "03",
+			ResourceForm:        "02",
+			ResourceLink:        cover,
+		}
+	}
+
+	return product
+}
+
+// onixBibliographicCodes are the ISO 639-2/B codes ONIX asks for where they differ from the terminology codes
+var onixBibliographicCodes = map[string]string{
+	"bod": "tib", "ces": "cze", "cym": "wel", "deu": "ger", "ell": "gre", "eus": "baq", "fas": "per",
+	"fra": "fre", "hye": "arm", "isl": "ice", "kat": "geo", "mkd": "mac", "mri": "mao", "msa": "may",
+	"mya": "bur", "nld": "dut", "ron": "rum", "slk": "slo", "sqi": "alb", "zho": "chi",
+}
+
+// onixLanguageCode turns a language tag into the three letter code of List 74, pt-BR is just por
+func onixLanguageCode(tag string) string {
+	if tag == "" {
+		return ""
+	}
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return ""
+	}
+	base, confidence := parsed.Base()
+	if confidence == language.No {
+		return ""
+	}
+
+	code := base.ISO3()
+	if bibliographic, ok := onixBibliographicCodes[code]; ok {
+		return bibliographic
+	}
+	return code
+}
+
+func (s *BookService) exportONIX(ctx context.Context, w io.Writer) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	message := xml.StartElement{
+		Name: xml.Name{Local: "ONIXMessage"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns"}, Value: "http://ns.editeur.org/onix/3.0/reference"},
+			{Name: xml.Name{Local: "release"}, Value: "3.0"},
+		},
+	}
+	err = encoder.EncodeToken(message)
+	if err != nil {
+		return err
+	}
+
+	err = encoder.Encode(&onixHeader{
+		SenderName:   s.onixSender,
+		SentDateTime: time.Now().UTC().Format("20060102T1504Z"),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.streamBooks(ctx, func(book *books.Book) error {
+		return encoder.Encode(s.newONIXProduct(book))
+	})
+	if err != nil {
+		return err
+	}
+
+	err = encoder.EncodeToken(message.End())
+	if err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/port"
+	"bookstore_api/tools"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+)
+
+// OrderLineRequest is a single book and the quantity the user wants of it
+type OrderLineRequest struct {
+	BookID   int64
+	Quantity int64
+}
+
+// PlaceOrderRequest ships either to an address from the address book, or to an inline address.
+// With neither, the default address of the user is used.
+type PlaceOrderRequest struct {
+	AddressID     int64
+	Address       *addresses.Address
+	PaymentMethod string
+	Lines         []OrderLineRequest
+}
+
+type OrderService struct {
+	orderRepo   port.OrderRepository
+	bookRepo    port.BookRepository
+	addressRepo port.AddressRepository
+}
+
+func NewOrderService(orderRepo port.OrderRepository, bookRepo port.BookRepository, addressRepo port.AddressRepository) *OrderService {
+	return &OrderService{
+		orderRepo:   orderRepo,
+		bookRepo:    bookRepo,
+		addressRepo: addressRepo,
+	}
+}
+
+// PlaceOrder builds the order, prices it off the current book prices and persists it
+func (s *OrderService) PlaceOrder(ctx context.Context, req *PlaceOrderRequest) (*orders.Order, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := s.shippingAddress(ctx, principal.UserID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := orders.NewOrder(principal.UserID, address, req.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range req.Lines {
+		err = order.AddLine(line.BookID, line.Quantity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Prices are always read server-side, whatever the client thinks the book costs is ignored
+	prices := make(map[int64]float64, len(order.Lines))
+	for _, bookID := range order.BookIDs() {
+		book, err := s.bookRepo.GetById(ctx, bookID)
+		if err != nil {
+			return nil, fmt.Errorf("book %d not found", bookID)
+		}
+		prices[bookID] = book.Price.Get()
+	}
+
+	err = order.Price(prices)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.orderRepo.Create(ctx, order)
+}
+
+// shippingAddress resolves the address the order ships to, always as a snapshot
+func (s *OrderService) shippingAddress(ctx context.Context, userID int64, req *PlaceOrderRequest) (*addresses.Address, error) {
+	if req.Address != nil {
+		return req.Address.Snapshot(), nil
+	}
+
+	if req.AddressID != 0 {
+		address, err := s.addressRepo.GetById(ctx, req.AddressID)
+		if err != nil || address.UserID != userID {
+			return nil, ErrAddressNotFound
+		}
+		return address.Snapshot(), nil
+	}
+
+	userAddresses, err := s.addressRepo.GetAllByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range userAddresses {
+		if address.IsDefault {
+			return address.Snapshot(), nil
+		}
+	}
+
+	return nil, orders.ErrInvalidAddress
+}
+
+// GetOrderById returns the order only if it belongs to the user
+func (s *OrderService) GetOrderById(ctx context.Context, id int64) (*orders.Order, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.orderRepo.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't leak the existence of other people's orders
+	if order.UserID != principal.UserID {
+		return nil, ErrOrderNotFound
+	}
+
+	return order, nil
+}
+
+// CancelOrder cancels the user's order and releases the stock reserved by it
+func (s *OrderService) CancelOrder(ctx context.Context, id int64) (*orders.Order, error) {
+	order, err := s.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = order.Cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.orderRepo.Cancel(ctx, order)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/payments"
+	"bookstore_api/internal/port"
+	"bookstore_api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var (
+	ErrPaymentNotFound     = errors.New("payment not found")
+	ErrPaymentExists       = errors.New("order already has a payment")
+	ErrUnsupportedProvider = errors.New("payment method is not supported")
+)
+
+type PaymentService struct {
+	paymentRepo port.PaymentRepository
+	orderRepo   port.OrderRepository
+	providers   map[orders.PaymentMethod]port.PaymentProvider
+}
+
+func NewPaymentService(paymentRepo port.PaymentRepository, orderRepo port.OrderRepository, providers ...port.PaymentProvider) *PaymentService {
+	registry := make(map[orders.PaymentMethod]port.PaymentProvider, len(providers))
+	for _, provider := range providers {
+		registry[provider.Method()] = provider
+	}
+
+	return &PaymentService{
+		paymentRepo: paymentRepo,
+		orderRepo:   orderRepo,
+		providers:   registry,
+	}
+}
+
+// CreatePayment opens a pending payment for the user's order with the provider of its payment method
+func (s *PaymentService) CreatePayment(ctx context.Context, orderID int64) (*payments.Payment, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.orderRepo.GetById(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != principal.UserID {
+		return nil, ErrOrderNotFound
+	}
+
+	if _, err = s.paymentRepo.GetByOrderId(ctx, orderID); err == nil {
+		return nil, ErrPaymentExists
+	}
+
+	payment, err := payments.NewPayment(order, principal.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.provider(payment.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.ProviderReference, err = provider.Initiate(ctx, payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate payment: %v", err)
+	}
+
+	return s.paymentRepo.Create(ctx, payment)
+}
+
+// GetPaymentByOrderId returns the payment of the user's order
+func (s *PaymentService) GetPaymentByOrderId(ctx context.Context, orderID int64) (*payments.Payment, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := s.paymentRepo.GetByOrderId(ctx, orderID)
+	if err != nil || payment.UserID != principal.UserID {
+		return nil, ErrPaymentNotFound
+	}
+
+	return payment, nil
+}
+
+// ConfirmPayment completes the payment, the money is only captured with the provider
+// once the payment and its order are still payable in the database
+func (s *PaymentService) ConfirmPayment(ctx context.Context, id int64) (*payments.Payment, error) {
+	payment, err := s.getOwnedPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = payment.Complete()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.provider(payment.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	captured := false
+	completed, err := s.paymentRepo.Complete(ctx, payment, func(ctx context.Context) error {
+		err := provider.Capture(ctx, payment.ProviderReference)
+		if err != nil {
+			return fmt.Errorf("failed to capture payment: %v", err)
+		}
+		captured = true
+		return nil
+	})
+	if err != nil && captured {
+		// The transaction didn't commit after the money moved, so it is given back rather than left captured
+		// behind a payment that stays pending
+		voidErr := provider.Void(context.WithoutCancel(ctx), payment.ProviderReference)
+		if voidErr != nil {
+			log.Printf("payment %d was captured as %s but not completed, reconcile it: %v", payment.ID.Get(), payment.ProviderReference, voidErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return completed, nil
+}
+
+// CancelPayment cancels the payment along with its order, the payment is only voided with the provider
+// once it is still pending in the database
+func (s *PaymentService) CancelPayment(ctx context.Context, id int64) (*payments.Payment, error) {
+	payment, err := s.getOwnedPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = payment.Cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.provider(payment.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	voided := false
+	canceled, err := s.paymentRepo.Cancel(ctx, payment, func(ctx context.Context) error {
+		err := provider.Void(ctx, payment.ProviderReference)
+		if err != nil {
+			return fmt.Errorf("failed to void payment: %v", err)
+		}
+		voided = true
+		return nil
+	})
+	if err != nil && voided {
+		// A void can't be taken back, the payment stays pending until someone reconciles it
+		log.Printf("payment %d was voided as %s but not canceled, reconcile it: %v", payment.ID.Get(), payment.ProviderReference, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return canceled, nil
+}
+
+// HandleEvent applies a provider webhook event to its payment, and reports whether the event is new.
+// Redelivered events are acknowledged without being applied twice.
+func (s *PaymentService) HandleEvent(ctx context.Context, event *payments.Event) (*payments.Payment, bool, error) {
+	payment, err := s.paymentRepo.GetByProviderReference(ctx, event.Reference)
+	if err != nil || payment.Method != event.Provider {
+		return nil, false, ErrPaymentNotFound
+	}
+
+	changed, err := payment.Apply(event.Status)
+	if err != nil {
+		return nil, false, err
+	}
+
+	recorded, err := s.paymentRepo.RecordEvent(ctx, event, payment, changed)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return payment, recorded, nil
+}
+
+func (s *PaymentService) getOwnedPayment(ctx context.Context, id int64) (*payments.Payment, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := s.paymentRepo.GetById(ctx, id)
+	if err != nil || payment.UserID != principal.UserID {
+		return nil, ErrPaymentNotFound
+	}
+
+	return payment, nil
+}
+
+func (s *PaymentService) provider(method orders.PaymentMethod) (port.PaymentProvider, error) {
+	provider, ok := s.providers[method]
+	if !ok {
+		return nil, ErrUnsupportedProvider
+	}
+	return provider, nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/reviews"
+	"bookstore_api/internal/port"
+	"bookstore_api/tools"
+	"context"
+	"errors"
+	"os"
+	"strconv"
+)
+
+var (
+	ErrReviewNotFound = errors.New("review not found")
+	ErrBookNotFound   = errors.New("book not found")
+	ErrNotPurchased   = errors.New("only customers who bought the book can review it")
+)
+
+type ReviewService struct {
+	requirePurchase bool
+	reviewRepo      port.ReviewRepository
+	bookRepo        port.BookRepository
+}
+
+func NewReviewService(reviewRepo port.ReviewRepository, bookRepo port.BookRepository) *ReviewService {
+	// Anyone signed in can review, unless REVIEWS_REQUIRE_PURCHASE is turned on
+	requirePurchase, _ := strconv.ParseBool(os.Getenv("REVIEWS_REQUIRE_PURCHASE"))
+
+	return &ReviewService{
+		requirePurchase: requirePurchase,
+		reviewRepo:      reviewRepo,
+		bookRepo:        bookRepo,
+	}
+}
+
+func (s *ReviewService) CreateReview(ctx context.Context, bookID int64, review *reviews.Review) (*reviews.Review, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.bookRepo.GetById(ctx, bookID)
+	if err != nil {
+		return nil, ErrBookNotFound
+	}
+
+	if s.requirePurchase {
+		purchased, err := s.reviewRepo.HasPurchased(ctx, principal.UserID, bookID)
+		if err != nil {
+			return nil, err
+		}
+		if !purchased {
+			return nil, ErrNotPurchased
+		}
+	}
+
+	review.UserID = principal.UserID
+	review.BookID = bookID
+
+	return s.reviewRepo.Create(ctx, review)
+}
+
+func (s *ReviewService) GetAllReviews(ctx context.Context, bookID int64, page int64) ([]*reviews.Review, error) {
+	return s.reviewRepo.GetAllByBookId(ctx, bookID, page)
+}
+
+func (s *ReviewService) UpdateReview(ctx context.Context, id int64, review *reviews.Review) (*reviews.Review, error) {
+	existingReview, err := s.getOwnedReview(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	existingReview.Edit(review)
+
+	return s.reviewRepo.Update(ctx, existingReview)
+}
+
+func (s *ReviewService) DeleteReview(ctx context.Context, id int64) error {
+	_, err := s.getOwnedReview(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.reviewRepo.Delete(ctx, id)
+}
+
+// getOwnedReview returns the review only if the user wrote it
+func (s *ReviewService) getOwnedReview(ctx context.Context, id int64) (*reviews.Review, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	review, err := s.reviewRepo.GetById(ctx, id)
+	if err != nil || review.UserID != principal.UserID {
+		return nil, ErrReviewNotFound
+	}
+
+	return review, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"context"
+	"log"
+	"time"
+)
+
+// RotationReport counts what a pass over the encrypted covers did
+type RotationReport struct {
+	Rotated int
+	Failed  int
+}
+
+// RotateCovers re-encrypts every cover that isn't written with the current key yet. A cover that can't be
+// decrypted with any key the cipher holds is left alone and counted as failed, the rest of the pass carries on.
+func (s *BookService) RotateCovers(ctx context.Context) (*RotationReport, error) {
+	report := &RotationReport{}
+
+	err := s.bookRepo.Stream(ctx, func(book *books.Book) error {
+		stored := book.CoverImage.Get()
+		if !s.cipher.NeedsRotation(stored) {
+			return nil
+		}
+
+		rotated, err := s.cipher.Rotate(stored)
+		if err != nil {
+			log.Printf("error rotating cover of book %d: %v", book.ID.Get(), err)
+			report.Failed++
+			return nil
+		}
+
+		// A cover changed since it was read keeps the change, it is already written with the current key
+		ok, err := s.bookRepo.UpdateCover(ctx, book.ID.Get(), stored, rotated)
+		if err != nil {
+			return err
+		}
+		if ok {
+			report.Rotated++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// RunCoverRotation rotates the covers right away and then every interval, until the context is done
+func (s *BookService) RunCoverRotation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.RotateCovers(ctx)
+		switch {
+		case err != nil:
+			log.Printf("error rotating covers: %v", err)
+		case report.Rotated > 0 || report.Failed > 0:
+			log.Printf("rotated %d covers to key %q, %d failed", report.Rotated, s.cipher.CurrentKeyID(), report.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type httpAddressDTORequest struct {
+	Address    string `json:"address"`
+	City       string `json:"city"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+func (d *httpAddressDTORequest) newAddress() (*addresses.Address, error) {
+	return addresses.NewAddress(d.Address, d.City, d.PostalCode, d.Country)
+}
+
+type httpAddressDTOResponse struct {
+	ID         int64  `json:"id"`
+	Address    string `json:"address"`
+	City       string `json:"city"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	IsDefault  bool   `json:"is_default"`
+
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func newResponseAddress(address *addresses.Address) *httpAddressDTOResponse {
+	return &httpAddressDTOResponse{
+		ID:         address.ID.Get(),
+		Address:    address.Address,
+		City:       address.City,
+		PostalCode: address.PostalCode.Get(),
+		Country:    address.Country.Get(),
+		IsDefault:  address.IsDefault,
+		CreatedAt:  address.CreatedAt,
+		UpdatedAt:  address.UpdatedAt,
+	}
+}
+
+type AddressHandler struct {
+	addressService *service.AddressService
+}
+
+func NewAddressHandler(addressService *service.AddressService) *AddressHandler {
+	return &AddressHandler{
+		addressService: addressService,
+	}
+}
+
+func (h *AddressHandler) GetAllAddresses(w http.ResponseWriter, r *http.Request) {
+	allAddresses, err := h.addressService.GetAllAddresses(r.Context())
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	allAddressesResponse := make([]*httpAddressDTOResponse, 0, len(allAddresses))
+	for _, address := range allAddresses {
+		allAddressesResponse = append(allAddressesResponse, newResponseAddress(address))
+	}
+
+	tools.RespondWithJSON(w, allAddressesResponse, http.StatusOK)
+}
+
+func (h *AddressHandler) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	addressDTO := &httpAddressDTORequest{}
+	if err := json.NewDecoder(r.Body).Decode(addressDTO); err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	address, err := addressDTO.newAddress()
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	createdAddress, err := h.addressService.CreateAddress(r.Context(), address)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseAddress(createdAddress), http.StatusCreated)
+}
+
+func (h *AddressHandler) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	addressDTO := &httpAddressDTORequest{}
+	if err := json.NewDecoder(r.Body).Decode(addressDTO); err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	address, err := addressDTO.newAddress()
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	updatedAddress, err := h.addressService.UpdateAddress(r.Context(), int64(id), address)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseAddress(updatedAddress), http.StatusOK)
+}
+
+func (h *AddressHandler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	err = h.addressService.DeleteAddress(r.Context(), int64(id))
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AddressHandler) SetDefaultAddress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	address, err := h.addressService.SetDefaultAddress(r.Context(), int64(id))
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseAddress(address), http.StatusOK)
+}
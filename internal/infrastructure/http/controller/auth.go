@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"bookstore_api/internal/port"
+	"bookstore_api/tools"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+var (
+	InvalidCredentials = errors.New("invalid credentials")
+	Forbidden          = errors.New("admin privileges required")
+	Unavailable        = errors.New("authentication is unavailable, try again later")
+)
+
+// Authenticator middleware rejects requests without a valid access token or with one on the denylist, and keeps
+// the principal of the token in the context. Tokens can't be checked while the denylist is down.
+func Authenticator(denylist port.TokenDenylist) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return authenticate(denylist, next)
+	}
+}
+
+func authenticate(denylist port.TokenDenylist, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			tools.RespondWithError(w, InvalidCredentials, http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := tools.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "), tools.AccessToken)
+		if err != nil {
+			tools.RespondWithError(w, InvalidCredentials, http.StatusUnauthorized)
+			return
+		}
+
+		denied, err := denylist.IsDenied(r.Context(), claims.RegisteredClaims.ID)
+		if err != nil {
+			log.Printf("error checking token denylist: %v", err)
+			tools.RespondWithError(w, Unavailable, http.StatusServiceUnavailable)
+			return
+		}
+		if denied {
+			tools.RespondWithError(w, InvalidCredentials, http.StatusUnauthorized)
+			return
+		}
+
+		ctx := tools.WithPrincipal(r.Context(), claims.Principal())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAdmin middleware only lets admins through, it has to run after Authenticator
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := tools.PrincipalFromContext(r.Context())
+		if err != nil {
+			tools.RespondWithError(w, InvalidCredentials, http.StatusUnauthorized)
+			return
+		}
+
+		if !principal.IsAdmin {
+			tools.RespondWithError(w, Forbidden, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/domain/authors"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+type httpAuthorDTOResponse struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+func newResponseAuthor(author *authors.Author) *httpAuthorDTOResponse {
+	return &httpAuthorDTOResponse{
+		ID:   author.ID.Get(),
+		Name: author.Name.Get(),
+		Slug: author.Slug.Get(),
+	}
+}
+
+// httpAuthorPageDTOResponse is the author along with a page of their books, paged like GET /books
+type httpAuthorPageDTOResponse struct {
+	*httpAuthorDTOResponse
+
+	Books *httpBookPageDTOResponse `json:"books"`
+}
+
+type AuthorHandler struct {
+	authorService *service.AuthorService
+}
+
+func NewAuthorHandler(authorService *service.AuthorService) *AuthorHandler {
+	return &AuthorHandler{
+		authorService: authorService,
+	}
+}
+
+func (h *AuthorHandler) GetAuthorPage(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListQuery(r)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	author, page, err := h.authorService.GetAuthorPage(r.Context(), chi.URLParam(r, "slug"), query)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	tools.RespondWithJSON(w, &httpAuthorPageDTOResponse{
+		httpAuthorDTOResponse: newResponseAuthor(author),
+		Books:                 newResponseBookPage(page),
+	}, http.StatusOK)
+}
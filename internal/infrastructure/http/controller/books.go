@@ -8,17 +8,154 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"math"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 var (
 	InvalidId      = errors.New("invalid id")
 	InvalidRequest = errors.New("invalid request body")
+	InvalidPage    = errors.New("invalid page number")
+	InvalidQuery   = errors.New("search query must be between 1 and 200 characters")
+	InvalidOrder   = errors.New("order must be asc or desc")
+	InvalidLimit   = errors.New("limit must be a positive number")
+	InvalidFilter  = errors.New("invalid filter")
+	InvalidDate    = errors.New("published_on must be a date like 2006-01-02")
 )
 
+// Define a regex pattern to allow only positive integers
+var pagePattern = regexp.MustCompile(`^[1-9]\d*$`)
+
+// parsePage reads the page query parameter, defaulting to the first page
+func parsePage(r *http.Request) (int64, error) {
+	pageStr := r.URL.Query().Get("page")
+	if pageStr == "" {
+		pageStr = "1"
+	}
+	if !pagePattern.MatchString(pageStr) {
+		return 0, InvalidPage
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		return 0, InvalidPage
+	}
+
+	return int64(page), nil
+}
+
+// parseListQuery reads the sort, order, cursor and limit query parameters of a listing
+func parseListQuery(r *http.Request) (*books.ListQuery, error) {
+	params := r.URL.Query()
+
+	sort, err := books.NewSortField(params.Get("sort"))
+	if err != nil {
+		return nil, err
+	}
+
+	var descending bool
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		descending = true
+	default:
+		return nil, InvalidOrder
+	}
+
+	var limit int
+	if limitStr := params.Get("limit"); limitStr != "" {
+		if !pagePattern.MatchString(limitStr) {
+			return nil, InvalidLimit
+		}
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			return nil, InvalidLimit
+		}
+	}
+
+	filter, err := parseFilter(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &books.ListQuery{
+		Filter:     *filter,
+		Sort:       sort,
+		Descending: descending,
+		Cursor:     params.Get("cursor"),
+		Limit:      limit,
+	}, nil
+}
+
+// parseFilter reads the filter query parameters, dates are either RFC 3339 timestamps or plain dates
+func parseFilter(params url.Values) (*books.Filter, error) {
+	parsePrice := func(key string) (*float64, error) {
+		value := params.Get(key)
+		if value == "" {
+			return nil, nil
+		}
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+			return nil, fmt.Errorf("%w: %s must be a number", InvalidFilter, key)
+		}
+		return &price, nil
+	}
+
+	parseDate := func(key string) (*time.Time, error) {
+		value := params.Get(key)
+		if value == "" {
+			return nil, nil
+		}
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if date, err := time.Parse(layout, value); err == nil {
+				date = date.UTC()
+				return &date, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s must be a date", InvalidFilter, key)
+	}
+
+	minPrice, err := parsePrice("min_price")
+	if err != nil {
+		return nil, err
+	}
+	maxPrice, err := parsePrice("max_price")
+	if err != nil {
+		return nil, err
+	}
+
+	var inStock bool
+	if value := params.Get("in_stock"); value != "" {
+		inStock, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: in_stock must be true or false", InvalidFilter)
+		}
+	}
+
+	createdAfter, err := parseDate("created_after")
+	if err != nil {
+		return nil, err
+	}
+	createdBefore, err := parseDate("created_before")
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := books.NewFilter(minPrice, maxPrice, inStock, createdAfter, createdBefore, params.Get("category"))
+	if err != nil {
+		return nil, err
+	}
+	filter.Author = params.Get("author")
+
+	return filter, nil
+}
+
 type httpBookDTORequest struct {
 	Title string `json:"title"`
 
@@ -27,6 +164,13 @@ type httpBookDTORequest struct {
 
 	Price float64 `json:"price"`
 	Stock int64   `json:"stock"`
+
+	ISBN        string   `json:"isbn"`
+	Authors     []string `json:"authors"`
+	Publisher   string   `json:"publisher"`
+	PageCount   int64    `json:"page_count"`
+	Language    string   `json:"language"`
+	PublishedOn string   `json:"published_on"`
 }
 
 func (d *httpBookDTORequest) newBook() (*books.Book, error) {
@@ -35,6 +179,28 @@ func (d *httpBookDTORequest) newBook() (*books.Book, error) {
 		return nil, err
 	}
 
+	var publishedOn *time.Time
+	if d.PublishedOn != "" {
+		date, err := time.Parse(time.DateOnly, d.PublishedOn)
+		if err != nil {
+			return nil, InvalidDate
+		}
+		publishedOn = &date
+	}
+
+	book.Details, err = books.NewDetails(d.ISBN, d.Publisher, d.PageCount, d.Language, publishedOn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Leaving the authors out keeps them as they are on an update
+	if d.Authors != nil {
+		err = book.SetAuthors(d.Authors)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return book, nil
 }
 
@@ -49,11 +215,33 @@ type httpBookDTOResponse struct {
 	Price float64 `json:"price"`
 	Stock int64   `json:"stock"`
 
+	Rating     float64 `json:"rating"`
+	NumReviews int64   `json:"num_reviews"`
+
+	Categories []string                 `json:"categories"`
+	Authors    []*httpAuthorDTOResponse `json:"authors"`
+
+	ISBN        string `json:"isbn,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	PageCount   int64  `json:"page_count,omitempty"`
+	Language    string `json:"language,omitempty"`
+	PublishedOn string `json:"published_on,omitempty"`
+
 	CreatedAt *time.Time `json:"created_at"`
 	UpdatedAt *time.Time `json:"updated_at"`
 }
 
 func newResponseBook(book *books.Book) *httpBookDTOResponse {
+	bookAuthors := make([]*httpAuthorDTOResponse, 0, len(book.Authors))
+	for _, author := range book.Authors {
+		bookAuthors = append(bookAuthors, newResponseAuthor(author))
+	}
+
+	var publishedOn string
+	if book.Details.PublishedOn != nil {
+		publishedOn = book.Details.PublishedOn.Format(time.DateOnly)
+	}
+
 	return &httpBookDTOResponse{
 		ID:         book.ID.Get(),
 		Title:      book.Title.Get(),
@@ -62,8 +250,100 @@ func newResponseBook(book *books.Book) *httpBookDTOResponse {
 		Synopsis:   book.Synopsis.Get(),
 		Price:      book.Price.Get(),
 		Stock:      book.Stock.Get(),
-		CreatedAt:  book.CreatedAt,
-		UpdatedAt:  book.UpdatedAt,
+		Rating:     book.Rating.Average(),
+		NumReviews: book.Rating.Count(),
+		Categories: book.Categories,
+		Authors:    bookAuthors,
+
+		ISBN:        book.Details.ISBN.Get(),
+		Publisher:   book.Details.Publisher.Get(),
+		PageCount:   book.Details.PageCount.Get(),
+		Language:    book.Details.Language.Get(),
+		PublishedOn: publishedOn,
+
+		CreatedAt: book.CreatedAt,
+		UpdatedAt: book.UpdatedAt,
+	}
+}
+
+type httpBookPageDTOResponse struct {
+	Books      []*httpBookDTOResponse `json:"books"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+	PrevCursor string                 `json:"prev_cursor,omitempty"`
+	Total      int64                  `json:"total"`
+	Facets     httpBookFacetsDTO      `json:"facets"`
+}
+
+type httpBookFacetsDTO struct {
+	Price      []httpPriceBandDTO     `json:"price"`
+	InStock    int64                  `json:"in_stock"`
+	Categories []httpCategoryCountDTO `json:"categories"`
+}
+
+type httpPriceBandDTO struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int64    `json:"count"`
+}
+
+type httpCategoryCountDTO struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+func newResponseBookPage(page *books.Page) *httpBookPageDTOResponse {
+	pageResponse := &httpBookPageDTOResponse{
+		Books:      make([]*httpBookDTOResponse, 0, len(page.Books)),
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		Total:      page.Total,
+	}
+	for _, book := range page.Books {
+		pageResponse.Books = append(pageResponse.Books, newResponseBook(book))
+	}
+
+	pageResponse.Facets.InStock = page.Facets.InStock
+	pageResponse.Facets.Price = make([]httpPriceBandDTO, 0, len(page.Facets.PriceBands))
+	for _, band := range page.Facets.PriceBands {
+		pageResponse.Facets.Price = append(pageResponse.Facets.Price, httpPriceBandDTO{
+			Min:   band.Min,
+			Max:   band.Max,
+			Count: band.Count,
+		})
+	}
+	pageResponse.Facets.Categories = make([]httpCategoryCountDTO, 0, len(page.Facets.Categories))
+	for _, category := range page.Facets.Categories {
+		pageResponse.Facets.Categories = append(pageResponse.Facets.Categories, httpCategoryCountDTO{
+			Slug:  category.Slug,
+			Name:  category.Name,
+			Count: category.Count,
+		})
+	}
+
+	return pageResponse
+}
+
+type httpBookSearchDTOResponse struct {
+	*httpBookDTOResponse
+
+	Rank      float64              `json:"rank"`
+	Highlight httpBookHighlightDTO `json:"highlight"`
+}
+
+type httpBookHighlightDTO struct {
+	Title    string `json:"title"`
+	Synopsis string `json:"synopsis"`
+}
+
+func newResponseSearchResult(result *books.SearchResult) *httpBookSearchDTOResponse {
+	return &httpBookSearchDTOResponse{
+		httpBookDTOResponse: newResponseBook(result.Book),
+		Rank:                result.Rank,
+		Highlight: httpBookHighlightDTO{
+			Title:    result.TitleHighlight,
+			Synopsis: result.SynopsisHighlight,
+		},
 	}
 }
 
@@ -86,7 +366,7 @@ func (h *BookHandler) CreateBook(w http.ResponseWriter, r *http.Request) {
 
 	book, err := bookDTO.newBook()
 	if err != nil {
-		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		tools.RespondWithError(w, err, http.StatusBadRequest)
 		return
 	}
 
@@ -118,37 +398,73 @@ func (h *BookHandler) GetBookById(w http.ResponseWriter, r *http.Request) {
 	tools.RespondWithJSON(w, bookResp, http.StatusOK)
 }
 
-func (h *BookHandler) GetAllBooks(w http.ResponseWriter, r *http.Request) {
-	pageStr := r.URL.Query().Get("page")
-	if pageStr == "" {
-		pageStr = "1"
+func (h *BookHandler) GetBookBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	book, currentSlug, err := h.bookService.GetBookBySlug(r.Context(), slug)
+	if errors.Is(err, service.ErrBookNotFound) {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusInternalServerError)
+		return
 	}
-	// Define a regex pattern to allow only positive integers
-	re := regexp.MustCompile(`^[1-9]\d*$`)
-	if !re.MatchString(pageStr) {
-		tools.RespondWithError(w, fmt.Errorf("invalid page number"), http.StatusBadRequest)
+
+	// Old slugs move permanently, so shared links and search engines follow along
+	if currentSlug != "" {
+		http.Redirect(w, r, "/books/by-slug/"+url.PathEscape(currentSlug), http.StatusMovedPermanently)
 		return
 	}
 
-	page, err := strconv.Atoi(pageStr)
+	tools.RespondWithJSON(w, newResponseBook(book), http.StatusOK)
+}
+
+func (h *BookHandler) GetAllBooks(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListQuery(r)
 	if err != nil {
-		tools.RespondWithError(w, fmt.Errorf("invalid page number"), http.StatusBadRequest)
+		tools.RespondWithError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	allBooks, err := h.bookService.GetAllBooks(r.Context(), int64(page))
+	page, err := h.bookService.GetAllBooks(r.Context(), query)
 	if err != nil {
+		if errors.Is(err, tools.ErrInvalidCursor) || errors.Is(err, books.ErrInvalidSort) {
+			tools.RespondWithError(w, err, http.StatusBadRequest)
+			return
+		}
 		tools.RespondWithError(w, err, http.StatusNotFound)
 		return
 	}
 
-	var allBooksResponse []*httpBookDTOResponse
-	for _, book := range allBooks {
-		responseBook := newResponseBook(book)
-		allBooksResponse = append(allBooksResponse, responseBook)
+	tools.RespondWithJSON(w, newResponseBookPage(page), http.StatusOK)
+}
+
+func (h *BookHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" || utf8.RuneCountInString(query) > 200 {
+		tools.RespondWithError(w, InvalidQuery, http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
 	}
 
-	tools.RespondWithJSON(w, allBooksResponse, http.StatusOK)
+	results, err := h.bookService.SearchBooks(r.Context(), query, page)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	resultsResponse := make([]*httpBookSearchDTOResponse, 0, len(results))
+	for _, result := range results {
+		resultsResponse = append(resultsResponse, newResponseSearchResult(result))
+	}
+
+	tools.RespondWithJSON(w, resultsResponse, http.StatusOK)
 }
 
 func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
@@ -168,7 +484,7 @@ func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
 	// Book currently has no id
 	book, err := bookDTO.newBook()
 	if err != nil {
-		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		tools.RespondWithError(w, err, http.StatusBadRequest)
 		return
 	}
 
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/domain/categories"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+)
+
+type httpCategoryDTORequest struct {
+	Name string `json:"name"`
+}
+
+func (d *httpCategoryDTORequest) newCategory() (*categories.Category, error) {
+	return categories.NewCategory(d.Name)
+}
+
+type httpCategoryDTOResponse struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+func newResponseCategory(category *categories.Category) *httpCategoryDTOResponse {
+	return &httpCategoryDTOResponse{
+		ID:   category.ID.Get(),
+		Name: category.Name.Get(),
+		Slug: category.Slug.Get(),
+	}
+}
+
+type CategoryHandler struct {
+	categoryService *service.CategoryService
+}
+
+func NewCategoryHandler(categoryService *service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{
+		categoryService: categoryService,
+	}
+}
+
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	categoryDTO := &httpCategoryDTORequest{}
+	if err := json.NewDecoder(r.Body).Decode(categoryDTO); err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	category, err := categoryDTO.newCategory()
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	createdCategory, err := h.categoryService.CreateCategory(r.Context(), category)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseCategory(createdCategory), http.StatusCreated)
+}
+
+func (h *CategoryHandler) GetAllCategories(w http.ResponseWriter, r *http.Request) {
+	allCategories, err := h.categoryService.GetAllCategories(r.Context())
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	allCategoriesResponse := make([]*httpCategoryDTOResponse, 0, len(allCategories))
+	for _, category := range allCategories {
+		allCategoriesResponse = append(allCategoriesResponse, newResponseCategory(category))
+	}
+
+	tools.RespondWithJSON(w, allCategoriesResponse, http.StatusOK)
+}
+
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	categoryDTO := &httpCategoryDTORequest{}
+	if err = json.NewDecoder(r.Body).Decode(categoryDTO); err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	category, err := categoryDTO.newCategory()
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	updatedCategory, err := h.categoryService.UpdateCategory(r.Context(), int64(id), category)
+	if err != nil {
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			tools.RespondWithError(w, err, http.StatusNotFound)
+			return
+		}
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseCategory(updatedCategory), http.StatusOK)
+}
+
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	err = h.categoryService.DeleteCategory(r.Context(), int64(id))
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CategoryHandler) AttachCategory(w http.ResponseWriter, r *http.Request) {
+	bookID, categoryID, err := parseBookCategory(r)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = h.categoryService.AttachCategory(r.Context(), bookID, categoryID)
+	if err != nil {
+		if errors.Is(err, service.ErrBookNotFound) || errors.Is(err, service.ErrCategoryNotFound) {
+			tools.RespondWithError(w, err, http.StatusNotFound)
+			return
+		}
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CategoryHandler) DetachCategory(w http.ResponseWriter, r *http.Request) {
+	bookID, categoryID, err := parseBookCategory(r)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = h.categoryService.DetachCategory(r.Context(), bookID, categoryID)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseBookCategory(r *http.Request) (int64, int64, error) {
+	bookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return 0, 0, InvalidId
+	}
+
+	categoryID, err := strconv.Atoi(chi.URLParam(r, "categoryId"))
+	if err != nil {
+		return 0, 0, InvalidId
+	}
+
+	return int64(bookID), int64(categoryID), nil
+}
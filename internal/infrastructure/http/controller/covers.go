@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+var InvalidCover = errors.New("cover must be sent as the cover field of a multipart form")
+
+// coverCacheControl lets anyone cache a cover for good, a new upload always gets a new URL
+const coverCacheControl = "public, max-age=31536000, immutable"
+
+type httpCoverDTOResponse struct {
+	Original  string `json:"original"`
+	Medium    string `json:"medium"`
+	Thumbnail string `json:"thumbnail"`
+}
+
+type httpBookCoverDTOResponse struct {
+	*httpBookDTOResponse
+
+	Covers httpCoverDTOResponse `json:"covers"`
+}
+
+type CoverHandler struct {
+	coverService *service.CoverService
+}
+
+func NewCoverHandler(coverService *service.CoverService) *CoverHandler {
+	return &CoverHandler{
+		coverService: coverService,
+	}
+}
+
+func (h *CoverHandler) UploadCover(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	// Room for the multipart framing around the image itself
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxCoverSize+1<<20)
+
+	file, _, err := r.FormFile("cover")
+	if err != nil {
+		var sizeErr *http.MaxBytesError
+		if errors.As(err, &sizeErr) {
+			tools.RespondWithError(w, service.ErrCoverSize, http.StatusRequestEntityTooLarge)
+			return
+		}
+		tools.RespondWithError(w, InvalidCover, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	book, cover, err := h.coverService.UploadCover(r.Context(), int64(id), file)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBookNotFound):
+			tools.RespondWithError(w, err, http.StatusNotFound)
+		case errors.Is(err, service.ErrCoverSize):
+			tools.RespondWithError(w, err, http.StatusRequestEntityTooLarge)
+		case errors.Is(err, service.ErrCoverType):
+			tools.RespondWithError(w, err, http.StatusUnsupportedMediaType)
+		case errors.Is(err, service.ErrCoverDimensions):
+			tools.RespondWithError(w, err, http.StatusUnprocessableEntity)
+		default:
+			tools.RespondWithError(w, err, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tools.RespondWithJSON(w, &httpBookCoverDTOResponse{
+		httpBookDTOResponse: newResponseBook(book),
+		Covers: httpCoverDTOResponse{
+			Original:  cover.Original,
+			Medium:    cover.Medium,
+			Thumbnail: cover.Thumbnail,
+		},
+	}, http.StatusOK)
+}
+
+func (h *CoverHandler) ServeCover(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+	version, file := chi.URLParam(r, "version"), chi.URLParam(r, "file")
+
+	blob, info, err := h.coverService.OpenCover(r.Context(), int64(id), version, file)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+	defer blob.Close()
+
+	etag := strconv.Quote(version + "-" + file)
+	w.Header().Set("Cache-Control", coverCacheControl)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// ServeContent answers conditional and range requests when the storage can seek
+	if seeker, ok := blob.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, file, info.ModTime, seeker)
+		return
+	}
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, blob)
+}
@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+func (h *BookHandler) ExportBooks(w http.ResponseWriter, r *http.Request) {
+	format, err := service.NewExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("books-%s.%s", time.Now().UTC().Format("20060102"), format.Extension())
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// The status is sent with the first row, an error after it can only cut the feed short
+	err = h.bookService.ExportBooks(r.Context(), w, format)
+	if err != nil {
+		log.Printf("error exporting books: %v", err)
+	}
+}
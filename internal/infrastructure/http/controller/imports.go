@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"errors"
+	"mime"
+	"net/http"
+)
+
+// maxImportSize keeps a single feed to a size we are willing to hold a request open for
+const maxImportSize = 32 << 20
+
+type httpImportLineDTOResponse struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type httpImportDTOResponse struct {
+	Created  int                          `json:"created"`
+	Updated  int                          `json:"updated"`
+	Rejected int                          `json:"rejected"`
+	Lines    []*httpImportLineDTOResponse `json:"lines"`
+
+	// Truncated is set when the feed broke off, the lines after the last one reported were never read
+	Truncated bool   `json:"truncated"`
+	Error     string `json:"error,omitempty"`
+}
+
+func newResponseImport(report *service.ImportReport) *httpImportDTOResponse {
+	lines := make([]*httpImportLineDTOResponse, 0, len(report.Lines))
+	for _, line := range report.Lines {
+		lineResponse := &httpImportLineDTOResponse{
+			Line:   line.Line,
+			Status: string(line.Status),
+			ID:     line.BookID,
+			Title:  line.Title,
+		}
+		if line.Err != nil {
+			lineResponse.Error = line.Err.Error()
+		}
+		lines = append(lines, lineResponse)
+	}
+
+	return &httpImportDTOResponse{
+		Created:  report.Created,
+		Updated:  report.Updated,
+		Rejected: report.Rejected,
+		Lines:    lines,
+	}
+}
+
+// parseImportFormat takes the format query parameter, falling back on the content type of the body
+func parseImportFormat(r *http.Request) (service.ImportFormat, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return service.NewImportFormat(format)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return service.ImportCSV, nil
+	case "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+		return service.ImportJSONL, nil
+	default:
+		return "", service.ErrImportFormat
+	}
+}
+
+func (h *BookHandler) ImportBooks(w http.ResponseWriter, r *http.Request) {
+	format, err := parseImportFormat(r)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	report, err := h.bookService.ImportBooks(r.Context(), r.Body, format)
+	if err != nil {
+		code := http.StatusBadRequest
+		var sizeErr *http.MaxBytesError
+		if errors.As(err, &sizeErr) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		if report == nil {
+			tools.RespondWithError(w, err, code)
+			return
+		}
+
+		// The lines before the feed broke off are saved already, the admin needs to know which ones they were
+		response := newResponseImport(report)
+		response.Truncated = true
+		response.Error = err.Error()
+		tools.RespondWithJSON(w, response, code)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseImport(report), http.StatusOK)
+}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"bookstore_api/tools"
+	"errors"
+	"log"
+	"net/http"
+)
+
+var SigningKeysUnavailable = errors.New("signing keys are unavailable")
+
+// jwksMaxAge is how long verifiers may cache the key set, a new key should be published at least this long before it signs
+const jwksMaxAge = "300"
+
+// JWKS serves the public keys tokens are signed with, so other services can verify tokens without being able to mint them
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	keys, err := tools.TokenKeysFromEnv()
+	if err != nil {
+		log.Printf("error loading signing keys: %v", err)
+		tools.RespondWithError(w, SigningKeysUnavailable, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age="+jwksMaxAge)
+	tools.RespondWithJSON(w, keys.JWKS(), http.StatusOK)
+}
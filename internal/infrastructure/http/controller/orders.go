@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type httpOrderLineDTO struct {
+	ID        int64   `json:"id,omitempty"`
+	BookID    int64   `json:"book_id"`
+	Quantity  int64   `json:"quantity"`
+	UnitPrice float64 `json:"unit_price,omitempty"`
+}
+
+type httpOrderDTORequest struct {
+	AddressID     int64                  `json:"address_id"`
+	Address       *httpAddressDTORequest `json:"address"`
+	PaymentMethod string                 `json:"payment_method"`
+	Books         []*httpOrderLineDTO    `json:"books"`
+}
+
+func (d *httpOrderDTORequest) newPlaceOrderRequest() (*service.PlaceOrderRequest, error) {
+	req := &service.PlaceOrderRequest{
+		AddressID:     d.AddressID,
+		PaymentMethod: d.PaymentMethod,
+	}
+
+	if d.Address != nil {
+		address, err := d.Address.newAddress()
+		if err != nil {
+			return nil, err
+		}
+		req.Address = address
+	}
+
+	for _, book := range d.Books {
+		req.Lines = append(req.Lines, service.OrderLineRequest{BookID: book.BookID, Quantity: book.Quantity})
+	}
+
+	return req, nil
+}
+
+type httpOrderDTOResponse struct {
+	ID      int64                   `json:"id"`
+	UserID  int64                   `json:"user_id"`
+	Address *httpAddressDTOResponse `json:"address"`
+
+	ProductPrice float64 `json:"product_price"`
+	TaxFee       float64 `json:"tax_fee"`
+	TotalPrice   float64 `json:"total_price"`
+
+	PaymentMethod string              `json:"payment_method"`
+	Status        string              `json:"status"`
+	Books         []*httpOrderLineDTO `json:"books"`
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+func newResponseOrder(order *orders.Order) *httpOrderDTOResponse {
+	lines := make([]*httpOrderLineDTO, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		lines = append(lines, &httpOrderLineDTO{
+			ID:        line.ID.Get(),
+			BookID:    line.BookID,
+			Quantity:  line.Quantity.Get(),
+			UnitPrice: line.UnitPrice.Get(),
+		})
+	}
+
+	return &httpOrderDTOResponse{
+		ID:            order.ID.Get(),
+		UserID:        order.UserID,
+		Address:       newResponseAddress(order.Address),
+		ProductPrice:  order.ProductPrice.Get(),
+		TaxFee:        order.TaxFee.Get(),
+		TotalPrice:    order.TotalPrice.Get(),
+		PaymentMethod: order.PaymentMethod.Get(),
+		Status:        order.Status.Get(),
+		Books:         lines,
+		CreatedAt:     order.CreatedAt,
+		UpdatedAt:     order.UpdatedAt,
+	}
+}
+
+type OrderHandler struct {
+	orderService *service.OrderService
+}
+
+func NewOrderHandler(orderService *service.OrderService) *OrderHandler {
+	return &OrderHandler{
+		orderService: orderService,
+	}
+}
+
+func (h *OrderHandler) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	orderDTO := &httpOrderDTORequest{}
+	if err := json.NewDecoder(r.Body).Decode(orderDTO); err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	req, err := orderDTO.newPlaceOrderRequest()
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderService.PlaceOrder(r.Context(), req)
+	if err != nil {
+		var stockErr *orders.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			tools.RespondWithJSON(w, map[string]any{"error": stockErr.Error(), "books": stockErr.Shortages}, http.StatusConflict)
+			return
+		}
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseOrder(order), http.StatusCreated)
+}
+
+func (h *OrderHandler) GetOrderById(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderService.GetOrderById(r.Context(), int64(id))
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseOrder(order), http.StatusOK)
+}
+
+func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.orderService.CancelOrder(r.Context(), int64(id))
+	if err != nil {
+		if errors.Is(err, orders.ErrNotCancelable) {
+			tools.RespondWithError(w, err, http.StatusConflict)
+			return
+		}
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseOrder(order), http.StatusOK)
+}
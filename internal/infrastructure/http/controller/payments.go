@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/domain/payments"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type httpPaymentDTOResponse struct {
+	ID      int64 `json:"id"`
+	OrderID int64 `json:"order_id"`
+
+	PaymentMethod     string  `json:"payment_method"`
+	Amount            float64 `json:"amount"`
+	Status            string  `json:"status"`
+	EmailAddress      string  `json:"email_address"`
+	ProviderReference string  `json:"provider_reference"`
+
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CanceledAt  *time.Time `json:"canceled_at,omitempty"`
+}
+
+func newResponsePayment(payment *payments.Payment) *httpPaymentDTOResponse {
+	return &httpPaymentDTOResponse{
+		ID:                payment.ID.Get(),
+		OrderID:           payment.OrderID,
+		PaymentMethod:     payment.Method.Get(),
+		Amount:            payment.Amount.Get(),
+		Status:            payment.Status.Get(),
+		EmailAddress:      payment.EmailAddress,
+		ProviderReference: payment.ProviderReference,
+		CreatedAt:         payment.CreatedAt,
+		UpdatedAt:         payment.UpdatedAt,
+		CompletedAt:       payment.CompletedAt,
+		CanceledAt:        payment.CanceledAt,
+	}
+}
+
+type PaymentHandler struct {
+	paymentService *service.PaymentService
+}
+
+func NewPaymentHandler(paymentService *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		paymentService: paymentService,
+	}
+}
+
+func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.paymentService.CreatePayment(r.Context(), int64(orderID))
+	if err != nil {
+		respondWithPaymentError(w, err)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponsePayment(payment), http.StatusCreated)
+}
+
+func (h *PaymentHandler) GetPaymentByOrderId(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.paymentService.GetPaymentByOrderId(r.Context(), int64(orderID))
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponsePayment(payment), http.StatusOK)
+}
+
+func (h *PaymentHandler) ConfirmPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.paymentService.ConfirmPayment(r.Context(), int64(id))
+	if err != nil {
+		respondWithPaymentError(w, err)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponsePayment(payment), http.StatusOK)
+}
+
+func (h *PaymentHandler) CancelPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.paymentService.CancelPayment(r.Context(), int64(id))
+	if err != nil {
+		respondWithPaymentError(w, err)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponsePayment(payment), http.StatusOK)
+}
+
+func respondWithPaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound), errors.Is(err, service.ErrPaymentNotFound):
+		tools.RespondWithError(w, err, http.StatusNotFound)
+	case errors.Is(err, service.ErrPaymentExists), errors.Is(err, payments.ErrInvalidTransition), errors.Is(err, payments.ErrOrderNotPayable):
+		tools.RespondWithError(w, err, http.StatusConflict)
+	default:
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+	}
+}
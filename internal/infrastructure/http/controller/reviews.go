@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/domain/reviews"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type httpReviewDTORequest struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+func (d *httpReviewDTORequest) newReview() (*reviews.Review, error) {
+	return reviews.NewReview(d.Rating, d.Comment)
+}
+
+type httpReviewDTOResponse struct {
+	ID      int64  `json:"id"`
+	UserID  int64  `json:"user_id"`
+	BookID  int64  `json:"book_id"`
+	Name    string `json:"name"`
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+func newResponseReview(review *reviews.Review) *httpReviewDTOResponse {
+	return &httpReviewDTOResponse{
+		ID:        review.ID.Get(),
+		UserID:    review.UserID,
+		BookID:    review.BookID,
+		Name:      review.Name,
+		Rating:    review.Rating.Get(),
+		Comment:   review.Comment.Get(),
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+	}
+}
+
+type ReviewHandler struct {
+	reviewService *service.ReviewService
+}
+
+func NewReviewHandler(reviewService *service.ReviewService) *ReviewHandler {
+	return &ReviewHandler{
+		reviewService: reviewService,
+	}
+}
+
+func (h *ReviewHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	reviewDTO := &httpReviewDTORequest{}
+	if err = json.NewDecoder(r.Body).Decode(reviewDTO); err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	review, err := reviewDTO.newReview()
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	createdReview, err := h.reviewService.CreateReview(r.Context(), int64(bookID), review)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBookNotFound):
+			tools.RespondWithError(w, err, http.StatusNotFound)
+		case errors.Is(err, service.ErrNotPurchased):
+			tools.RespondWithError(w, err, http.StatusForbidden)
+		default:
+			tools.RespondWithError(w, err, http.StatusBadRequest)
+		}
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseReview(createdReview), http.StatusCreated)
+}
+
+func (h *ReviewHandler) GetAllReviews(w http.ResponseWriter, r *http.Request) {
+	bookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	allReviews, err := h.reviewService.GetAllReviews(r.Context(), int64(bookID), page)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	allReviewsResponse := make([]*httpReviewDTOResponse, 0, len(allReviews))
+	for _, review := range allReviews {
+		allReviewsResponse = append(allReviewsResponse, newResponseReview(review))
+	}
+
+	tools.RespondWithJSON(w, allReviewsResponse, http.StatusOK)
+}
+
+func (h *ReviewHandler) UpdateReview(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	reviewDTO := &httpReviewDTORequest{}
+	if err = json.NewDecoder(r.Body).Decode(reviewDTO); err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	review, err := reviewDTO.newReview()
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	updatedReview, err := h.reviewService.UpdateReview(r.Context(), int64(id), review)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	tools.RespondWithJSON(w, newResponseReview(updatedReview), http.StatusOK)
+}
+
+func (h *ReviewHandler) DeleteReview(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.RespondWithError(w, InvalidId, http.StatusBadRequest)
+		return
+	}
+
+	err = h.reviewService.DeleteReview(r.Context(), int64(id))
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
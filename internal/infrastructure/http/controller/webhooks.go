@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/payments"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/tools"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"net/http"
+	"os"
+)
+
+var (
+	WebhookSecretError = errors.New("payment webhook secret is not set")
+	InvalidSignature   = errors.New("invalid signature")
+)
+
+// maxWebhookBody caps how much of the request is read before the signature is checked
+const maxWebhookBody = 1 << 20
+
+type httpPaymentEventDTORequest struct {
+	ID        string `json:"id"`
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+}
+
+type WebhookHandler struct {
+	secret         []byte
+	paymentService *service.PaymentService
+}
+
+func NewWebhookHandler(paymentService *service.PaymentService) (*WebhookHandler, error) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, WebhookSecretError
+	}
+
+	return &WebhookHandler{
+		secret:         []byte(secret),
+		paymentService: paymentService,
+	}, nil
+}
+
+func (h *WebhookHandler) HandlePaymentEvent(w http.ResponseWriter, r *http.Request) {
+	provider, err := orders.NewPaymentMethod(chi.URLParam(r, "provider"))
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	// Nothing in the body is trusted before the signature checks out
+	if !tools.VerifySignature(body, r.Header.Get("X-Webhook-Signature"), h.secret) {
+		tools.RespondWithError(w, InvalidSignature, http.StatusUnauthorized)
+		return
+	}
+
+	eventDTO := &httpPaymentEventDTORequest{}
+	if err = json.Unmarshal(body, eventDTO); err != nil {
+		tools.RespondWithError(w, InvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	event, err := payments.NewEvent(eventDTO.ID, provider, eventDTO.Reference, eventDTO.Status)
+	if err != nil {
+		tools.RespondWithError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	payment, recorded, err := h.paymentService.HandleEvent(r.Context(), event)
+	if err != nil {
+		respondWithPaymentError(w, err)
+		return
+	}
+
+	// Providers only need a 2xx, the payload is there for whoever is debugging the webhook
+	tools.RespondWithJSON(w, map[string]any{
+		"duplicate": !recorded,
+		"payment":   newResponsePayment(payment),
+	}, http.StatusOK)
+}
@@ -4,7 +4,6 @@ import (
 	"bookstore_api/internal/services"
 	"bookstore_api/models"
 	"bookstore_api/tools"
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -59,23 +58,35 @@ func (h *BookHandler) GetBookById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := context.WithValue(r.Context(), "cachedKey", cacheKey)
+	ctx := tools.WithCacheKey(r.Context(), cacheKey)
 	tools.RespondWithJSONAndCache(w, r.WithContext(ctx), h.Cache, book, http.StatusOK)
 }
 
 func (h *BookHandler) GetAllBooks(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	page := query.Get("page")
 
-	ctx := context.WithValue(r.Context(), "page", page)
+	listQuery := &models.BookListQuery{
+		Sort:       query.Get("sort"),
+		Descending: query.Get("order") == "desc",
+		Cursor:     query.Get("cursor"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		listQuery.Limit, err = strconv.Atoi(limit)
+		if err != nil || listQuery.Limit <= 0 {
+			tools.RespondWithError(w, errors.New("invalid limit"), http.StatusBadRequest)
+			return
+		}
+	}
 
-	books, err := h.bookService.GetAllBooks(ctx)
+	page, err := h.bookService.GetAllBooks(r.Context(), listQuery)
 	if err != nil {
-		tools.RespondWithError(w, err, http.StatusNotFound)
+		tools.RespondWithError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	tools.RespondWithJSON(w, books, http.StatusOK)
+	tools.RespondWithJSON(w, page, http.StatusOK)
 }
 
 func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
@@ -101,7 +112,7 @@ func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := context.WithValue(r.Context(), "cachedKey", cacheKey)
+	ctx := tools.WithCacheKey(r.Context(), cacheKey)
 	tools.RespondWithJSONAndCache(w, r.WithContext(ctx), h.Cache, updatedBook, http.StatusOK)
 }
 
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"bookstore_api/internal/services"
+	"bookstore_api/models"
+	"bookstore_api/tools"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"net"
+	"net/http"
+)
+
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.sessionService.ListSessions(r.Context())
+	if err != nil {
+		tools.RespondWithError(w, errors.New("failed to list sessions"), http.StatusInternalServerError)
+		return
+	}
+
+	tools.RespondWithJSON(w, sessions, http.StatusOK)
+}
+
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	err := h.sessionService.RevokeUserSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			tools.RespondWithError(w, err, http.StatusNotFound)
+			return
+		}
+		tools.RespondWithError(w, errors.New("failed to revoke session"), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions signs out everywhere else, the session making the request stays
+func (h *UserHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	revoked, err := h.sessionService.RevokeOtherSessions(r.Context())
+	if err != nil {
+		tools.RespondWithError(w, errors.New("failed to revoke sessions"), http.StatusInternalServerError)
+		return
+	}
+
+	tools.RespondWithJSON(w, &models.RevokeSessionsResponse{Revoked: revoked}, http.StatusOK)
+}
+
+// clientIP is the address the request came from, forwarded headers aren't trusted since anyone can set them
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -4,7 +4,6 @@ import (
 	"bookstore_api/internal/services"
 	"bookstore_api/models"
 	"bookstore_api/tools"
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -57,36 +56,29 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessClaims, accessToken, err := tools.GenerateToken(userResponse.Email, userResponse.IsAdmin, 30*time.Minute)
-	if err != nil {
-		tools.RespondWithError(w, errors.New("failed to generate tokens"), http.StatusInternalServerError)
-		return
+	principal := &tools.Principal{
+		UserID:  userResponse.ID,
+		Email:   userResponse.Email,
+		IsAdmin: userResponse.IsAdmin,
 	}
 
-	sessionClaims, sessionToken, err := tools.GenerateToken(userResponse.Email, userResponse.IsAdmin, 24*time.Hour)
+	session, sessionClaims, sessionToken, err := h.sessionService.CreateSession(r.Context(), principal, r.UserAgent(), clientIP(r))
 	if err != nil {
 		tools.RespondWithError(w, errors.New("failed to generate sessions"), http.StatusInternalServerError)
 		return
 	}
 
-	session := &models.Sessions{
-		ID:           sessionClaims.RegisteredClaims.ID,
-		UserEmail:    userResponse.Email,
-		RefreshToken: sessionToken,
-		IsRevoked:    false,
-		CreatedAt:    sessionClaims.IssuedAt.Time,
-		ExpiresAt:    sessionClaims.ExpiresAt.Time,
-	}
-
-	_, err = h.sessionService.CreateSession(r.Context(), session)
+	// The access token points back to the session it was issued for
+	principal.SessionID = session.ID
+	accessClaims, accessToken, err := h.sessionService.IssueAccessToken(r.Context(), principal, 30*time.Minute)
 	if err != nil {
-		tools.RespondWithError(w, errors.New("failed to generate sessions"), http.StatusInternalServerError)
+		tools.RespondWithError(w, errors.New("failed to generate tokens"), http.StatusInternalServerError)
 		return
 	}
 
 	loginResponse := models.UserLoginResponse{
 		User:       *userResponse,
-		SessionsId: sessionClaims.RegisteredClaims.ID,
+		SessionsId: session.ID,
 
 		AccessToken:          accessToken,
 		AccessTokenExpiresAt: accessClaims.ExpiresAt.Time,
@@ -106,13 +98,13 @@ func (h *UserHandler) LogoutUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := tools.ValidateToken(authHeader)
+	session, err := h.sessionService.GetSessionByRefreshToken(r.Context(), authHeader)
 	if err != nil {
 		tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
 		return
 	}
 
-	err = h.sessionService.DeleteSession(r.Context(), claims.RegisteredClaims.ID)
+	err = h.sessionService.DeleteSession(r.Context(), session.ID)
 	if err != nil {
 		tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
 		return
@@ -122,21 +114,13 @@ func (h *UserHandler) LogoutUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
-	authHeader, err := getBearerToken(r)
-	if err != nil {
-		tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
-		return
-	}
-
-	ctx := context.WithValue(r.Context(), "token", authHeader)
-
 	userData := &models.UserUpdateData{}
 	if err := json.NewDecoder(r.Body).Decode(userData); err != nil {
 		tools.RespondWithError(w, errors.New("invalid request body"), http.StatusBadRequest)
 		return
 	}
 
-	updatedUser, err := h.userService.UpdateUserData(ctx, userData)
+	updatedUser, err := h.userService.UpdateUserData(r.Context(), userData)
 	if err != nil {
 		tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
 		return
@@ -152,40 +136,30 @@ func (h *UserHandler) RefreshAccessToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	claims, err := tools.ValidateToken(authHeader)
+	// Every refresh trades the refresh token in for a new one, the one sent is no good afterwards
+	session, refreshClaims, refreshToken, err := h.sessionService.RotateRefreshToken(r.Context(), authHeader)
 	if err != nil {
-		tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
-		return
-	}
-
-	// Use claims.RegisteredClaims.ID instead of claims.ID
-	session, err := h.sessionService.GetSession(r.Context(), claims.RegisteredClaims.ID)
-	if err != nil {
-		tools.RespondWithError(w, errors.New("invalid session"), http.StatusUnauthorized)
-		return
-	}
-
-	if claims.Subject != session.UserEmail {
-		tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused), errors.Is(err, services.ErrSessionRevoked):
+			tools.RespondWithError(w, errors.New("revoked session"), http.StatusUnauthorized)
+		case errors.Is(err, services.ErrSessionExpired):
+			tools.RespondWithError(w, errors.New("session is expired"), http.StatusUnauthorized)
+		default:
+			tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
+		}
 		return
 	}
 
-	if session.IsRevoked {
+	accessClaims, accessToken, err := h.sessionService.IssueAccessToken(r.Context(), &tools.Principal{
+		UserID:    refreshClaims.UserID,
+		Email:     session.UserEmail,
+		IsAdmin:   refreshClaims.IsAdmin,
+		SessionID: session.ID,
+	}, 15*time.Minute)
+	if errors.Is(err, services.ErrSessionRevoked) {
 		tools.RespondWithError(w, errors.New("revoked session"), http.StatusUnauthorized)
 		return
 	}
-
-	if session.ExpiresAt.Before(time.Now()) {
-		err = h.sessionService.RevokeSession(r.Context(), session.ID)
-		if err != nil {
-			tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
-			return
-		}
-		tools.RespondWithError(w, errors.New("session is expired"), http.StatusUnauthorized)
-		return
-	}
-
-	_, accessToken, err := tools.GenerateToken(session.UserEmail, claims.IsAdmin, 15*time.Minute)
 	if err != nil {
 		tools.RespondWithError(w, errors.New("failed to generate tokens"), http.StatusInternalServerError)
 		return
@@ -193,7 +167,10 @@ func (h *UserHandler) RefreshAccessToken(w http.ResponseWriter, r *http.Request)
 
 	accessTokenResp := &models.RenewAccessTokenResponse{
 		AccessToken:          accessToken,
-		AccessTokenExpiresAt: claims.ExpiresAt.Time,
+		AccessTokenExpiresAt: accessClaims.ExpiresAt.Time,
+
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshClaims.ExpiresAt.Time,
 	}
 
 	w.Header().Set("Authorization", "Bearer "+accessToken)
@@ -207,13 +184,13 @@ func (h *UserHandler) RevokeAccessToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	claims, err := tools.ValidateToken(authHeader)
+	session, err := h.sessionService.GetSessionByRefreshToken(r.Context(), authHeader)
 	if err != nil {
 		tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
 		return
 	}
 
-	err = h.sessionService.RevokeSession(r.Context(), claims.RegisteredClaims.ID)
+	err = h.sessionService.RevokeSession(r.Context(), session.ID)
 	if err != nil {
 		tools.RespondWithError(w, errors.New("invalid credentials"), http.StatusUnauthorized)
 		return
@@ -7,16 +7,22 @@ import (
 	"bookstore_api/internal/port"
 	"bookstore_api/internal/repositories"
 	"bookstore_api/internal/services"
+	"bookstore_api/tools"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"log"
+	"net/http"
 )
 
 type Router struct {
 	Mux *chi.Mux
+
+	denylist     port.TokenDenylist
+	authenticate func(http.Handler) http.Handler
 }
 
-func NewRouter() *Router {
+// NewRouter builds the router, every authenticated route checks the denylist so logging out takes effect right away
+func NewRouter(denylist port.TokenDenylist) *Router {
 	// Initialize the router
 	mux := chi.NewRouter()
 
@@ -25,8 +31,20 @@ func NewRouter() *Router {
 	mux.Use(middleware.Recoverer)
 
 	return &Router{
-		Mux: mux,
+		Mux:          mux,
+		denylist:     denylist,
+		authenticate: controller.Authenticator(denylist),
+	}
+}
+
+// RegisterKeyRoutes publishes the public signing keys, the server doesn't start with keys it can't load
+func (r *Router) RegisterKeyRoutes() {
+	_, err := tools.TokenKeysFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
+
+	r.Mux.Get("/.well-known/jwks.json", controller.JWKS)
 }
 
 func (r *Router) RegisterBookRoutes(repository port.BookRepository) {
@@ -38,19 +56,149 @@ func (r *Router) RegisterBookRoutes(repository port.BookRepository) {
 	bookHandler := controller.NewBookHandler(bookService)
 
 	// Register book route
-	r.Mux.Post("/books", bookHandler.CreateBook)
 	r.Mux.Get("/books", bookHandler.GetAllBooks)
+	r.Mux.Get("/books/search", bookHandler.SearchBooks)
+	r.Mux.Get("/books/by-slug/{slug}", bookHandler.GetBookBySlug)
 	r.Mux.Get("/books/{id}", bookHandler.GetBookById)
-	r.Mux.Put("/books/{id}", bookHandler.UpdateBook)
-	r.Mux.Delete("/books/{id}", bookHandler.DeleteBook)
+
+	// Only admins get to change the catalog
+	r.Mux.Group(func(admin chi.Router) {
+		admin.Use(r.authenticate, controller.RequireAdmin)
+
+		admin.Post("/books", bookHandler.CreateBook)
+		admin.Get("/books/export", bookHandler.ExportBooks)
+		admin.Post("/books/import", bookHandler.ImportBooks)
+		admin.Put("/books/{id}", bookHandler.UpdateBook)
+		admin.Delete("/books/{id}", bookHandler.DeleteBook)
+	})
 }
 
-func (r *Router) RegisterUserRoutes(handler *handlers.Handler, service *services.Service, repository *repositories.Repository) {
-	userRepository := repositories.NewUserRepository(repository)
-	userService := services.NewUserService(service, userRepository)
+func (r *Router) RegisterAuthorRoutes(authorRepository port.AuthorRepository, bookRepository port.BookRepository) {
+	bookService, err := service.NewBookService(bookRepository)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	authorService := service.NewAuthorService(authorRepository, bookService)
+	authorHandler := controller.NewAuthorHandler(authorService)
+
+	// Register author route
+	r.Mux.Get("/authors/{slug}", authorHandler.GetAuthorPage)
+}
+
+func (r *Router) RegisterCoverRoutes(bookRepository port.BookRepository, storage port.BlobStorage) {
+	bookService, err := service.NewBookService(bookRepository)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	coverService := service.NewCoverService(bookService, storage)
+	coverHandler := controller.NewCoverHandler(coverService)
+
+	// Register cover route
+	r.Mux.Get("/covers/{id}/{version}/{file}", coverHandler.ServeCover)
+
+	r.Mux.Group(func(admin chi.Router) {
+		admin.Use(r.authenticate, controller.RequireAdmin)
+
+		admin.Put("/books/{id}/cover", coverHandler.UploadCover)
+	})
+}
+
+func (r *Router) RegisterCategoryRoutes(categoryRepository port.CategoryRepository, bookRepository port.BookRepository) {
+	categoryService := service.NewCategoryService(categoryRepository, bookRepository)
+	categoryHandler := controller.NewCategoryHandler(categoryService)
+
+	// Register category route
+	r.Mux.Get("/categories", categoryHandler.GetAllCategories)
+
+	r.Mux.Group(func(admin chi.Router) {
+		admin.Use(r.authenticate, controller.RequireAdmin)
 
+		admin.Post("/categories", categoryHandler.CreateCategory)
+		admin.Put("/categories/{id}", categoryHandler.UpdateCategory)
+		admin.Delete("/categories/{id}", categoryHandler.DeleteCategory)
+		admin.Put("/books/{id}/categories/{categoryId}", categoryHandler.AttachCategory)
+		admin.Delete("/books/{id}/categories/{categoryId}", categoryHandler.DetachCategory)
+	})
+}
+
+func (r *Router) RegisterReviewRoutes(reviewRepository port.ReviewRepository, bookRepository port.BookRepository) {
+	reviewService := service.NewReviewService(reviewRepository, bookRepository)
+	reviewHandler := controller.NewReviewHandler(reviewService)
+
+	// Register review route
+	r.Mux.Get("/books/{id}/reviews", reviewHandler.GetAllReviews)
+
+	r.Mux.Group(func(user chi.Router) {
+		user.Use(r.authenticate)
+
+		user.Post("/books/{id}/reviews", reviewHandler.CreateReview)
+		user.Put("/reviews/{id}", reviewHandler.UpdateReview)
+		user.Delete("/reviews/{id}", reviewHandler.DeleteReview)
+	})
+}
+
+func (r *Router) RegisterAddressRoutes(addressRepository port.AddressRepository) {
+	addressService := service.NewAddressService(addressRepository)
+	addressHandler := controller.NewAddressHandler(addressService)
+
+	// Register address route
+	r.Mux.Group(func(user chi.Router) {
+		user.Use(r.authenticate)
+
+		user.Get("/addresses", addressHandler.GetAllAddresses)
+		user.Post("/addresses", addressHandler.CreateAddress)
+		user.Put("/addresses/{id}", addressHandler.UpdateAddress)
+		user.Delete("/addresses/{id}", addressHandler.DeleteAddress)
+		user.Put("/addresses/{id}/default", addressHandler.SetDefaultAddress)
+	})
+}
+
+func (r *Router) RegisterOrderRoutes(orderRepository port.OrderRepository, bookRepository port.BookRepository, addressRepository port.AddressRepository) {
+	orderService := service.NewOrderService(orderRepository, bookRepository, addressRepository)
+	orderHandler := controller.NewOrderHandler(orderService)
+
+	// Register order route
+	r.Mux.Group(func(user chi.Router) {
+		user.Use(r.authenticate)
+
+		user.Post("/orders", orderHandler.PlaceOrder)
+		user.Get("/orders/{id}", orderHandler.GetOrderById)
+		user.Post("/orders/{id}/cancel", orderHandler.CancelOrder)
+	})
+}
+
+func (r *Router) RegisterPaymentRoutes(paymentRepository port.PaymentRepository, orderRepository port.OrderRepository, providers ...port.PaymentProvider) {
+	paymentService := service.NewPaymentService(paymentRepository, orderRepository, providers...)
+	paymentHandler := controller.NewPaymentHandler(paymentService)
+
+	// Register payment route
+	r.Mux.Group(func(user chi.Router) {
+		user.Use(r.authenticate)
+
+		user.Post("/orders/{id}/payment", paymentHandler.CreatePayment)
+		user.Get("/orders/{id}/payment", paymentHandler.GetPaymentByOrderId)
+		user.Post("/payments/{id}/confirm", paymentHandler.ConfirmPayment)
+		user.Post("/payments/{id}/cancel", paymentHandler.CancelPayment)
+	})
+
+	// Providers sign their webhooks instead of carrying a token, without PAYMENT_WEBHOOK_SECRET nothing can be verified
+	webhookHandler, err := controller.NewWebhookHandler(paymentService)
+	if err != nil {
+		log.Printf("payment webhooks are disabled: %v", err)
+		return
+	}
+
+	r.Mux.Post("/webhooks/payments/{provider}", webhookHandler.HandlePaymentEvent)
+}
+
+func (r *Router) RegisterUserRoutes(handler *handlers.Handler, service *services.Service, repository *repositories.Repository) {
 	sessionRepository := repositories.NewSessionRepository(repository)
-	sessionService := services.NewSessionService(service, sessionRepository)
+	sessionService := services.NewSessionService(service, sessionRepository, r.denylist)
+
+	userRepository := repositories.NewUserRepository(repository)
+	userService := services.NewUserService(service, userRepository, sessionService)
 
 	userHandler := handlers.NewUserHandler(handler, userService, sessionService)
 
@@ -58,8 +206,17 @@ func (r *Router) RegisterUserRoutes(handler *handlers.Handler, service *services
 	r.Mux.Post("/login", userHandler.LoginUser)
 	r.Mux.Post("/logout", userHandler.LogoutUser)
 
-	r.Mux.Put("/update", userHandler.UpdateUser)
+	r.Mux.With(r.authenticate).Put("/update", userHandler.UpdateUser)
 
 	r.Mux.Post("/refresh", userHandler.RefreshAccessToken)
 	r.Mux.Put("/revoke", userHandler.RevokeAccessToken)
+
+	// Users look after their own sessions, signing out a lost device from another one
+	r.Mux.Group(func(user chi.Router) {
+		user.Use(r.authenticate)
+
+		user.Get("/sessions", userHandler.ListSessions)
+		user.Delete("/sessions", userHandler.RevokeOtherSessions)
+		user.Delete("/sessions/{id}", userHandler.RevokeSession)
+	})
 }
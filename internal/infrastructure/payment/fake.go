@@ -0,0 +1,68 @@
+package payment
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/payments"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrAlreadySettled = errors.New("payment is already settled")
+)
+
+// FakeProvider is an in-process provider, it accepts every payment so the whole flow can run offline
+type FakeProvider struct {
+	method orders.PaymentMethod
+
+	mu       sync.Mutex
+	payments map[string]payments.Status
+}
+
+func NewFakeProvider(method orders.PaymentMethod) *FakeProvider {
+	return &FakeProvider{
+		method:   method,
+		payments: make(map[string]payments.Status),
+	}
+}
+
+func (p *FakeProvider) Method() orders.PaymentMethod {
+	return p.method
+}
+
+func (p *FakeProvider) Initiate(_ context.Context, _ *payments.Payment) (string, error) {
+	reference := "fake_" + uuid.NewString()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.payments[reference] = payments.Pending
+
+	return reference, nil
+}
+
+func (p *FakeProvider) Capture(_ context.Context, reference string) error {
+	return p.settle(reference, payments.Completed)
+}
+
+// Void cancels a pending payment, or refunds a captured one so a capture that didn't get recorded can be undone
+func (p *FakeProvider) Void(_ context.Context, reference string) error {
+	return p.settle(reference, payments.Canceled, payments.Completed)
+}
+
+// settle moves a pending payment, or one in any of the from statuses, to the status
+func (p *FakeProvider) settle(reference string, status payments.Status, from ...payments.Status) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// References from before a restart aren't known anymore, those are treated as pending
+	current, ok := p.payments[reference]
+	if ok && current != payments.Pending && !slices.Contains(from, current) {
+		return ErrAlreadySettled
+	}
+
+	p.payments[reference] = status
+	return nil
+}
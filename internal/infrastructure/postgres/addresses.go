@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type AddressRepository struct {
+	*Database
+}
+
+func NewAddressRepository(db *Database) *AddressRepository {
+	return &AddressRepository{
+		Database: db,
+	}
+}
+
+type dbAddressDTO struct {
+	ID         int64  `db:"id"`
+	UserID     int64  `db:"user_id"`
+	Address    string `db:"address"`
+	City       string `db:"city"`
+	PostalCode string `db:"postal_code"`
+	Country    string `db:"country"`
+	IsDefault  bool   `db:"is_default"`
+
+	CreatedAt *time.Time `db:"created_at"`
+	UpdatedAt *time.Time `db:"updated_at"`
+}
+
+func (d *dbAddressDTO) newAddress() (*addresses.Address, error) {
+	address, err := addresses.NewAddress(d.Address, d.City, d.PostalCode, d.Country)
+	if err != nil {
+		return nil, err
+	}
+
+	address.UserID = d.UserID
+	address.IsDefault = d.IsDefault
+	address.CreatedAt = d.CreatedAt
+	address.UpdatedAt = d.UpdatedAt
+
+	err = address.Create(d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+func newAddressDTO(address *addresses.Address) *dbAddressDTO {
+	return &dbAddressDTO{
+		ID:         address.ID.Get(),
+		UserID:     address.UserID,
+		Address:    address.Address,
+		City:       address.City,
+		PostalCode: address.PostalCode.Get(),
+		Country:    address.Country.Get(),
+		IsDefault:  address.IsDefault,
+		CreatedAt:  address.CreatedAt,
+		UpdatedAt:  address.UpdatedAt,
+	}
+}
+
+// Only rows with an owner belong to the address book, order snapshots are never listed or touched here
+const selectAddressQuery = `
+	SELECT id, user_id, address, city, postal_code, country, is_default, created_at, updated_at
+	FROM addresses
+`
+
+func (r *AddressRepository) Create(ctx context.Context, address *addresses.Address) (*addresses.Address, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if address.IsDefault {
+		_, err = tx.ExecContext(ctx, "UPDATE addresses SET is_default=FALSE WHERE user_id=$1 AND is_default", address.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("error while clearing default address: %v", err)
+		}
+	}
+
+	query, args, err := tx.BindNamed(`
+		INSERT INTO addresses (user_id, address, city, postal_code, country, is_default)
+		VALUES (:user_id, :address, :city, :postal_code, :country, :is_default)
+		RETURNING id, user_id, address, city, postal_code, country, is_default, created_at, updated_at
+	`, newAddressDTO(address))
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %v", err)
+	}
+
+	addressDTO := &dbAddressDTO{}
+	err = tx.GetContext(ctx, addressDTO, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error while inserting address: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing address: %v", err)
+	}
+
+	return addressDTO.newAddress()
+}
+
+func (r *AddressRepository) GetById(ctx context.Context, id int64) (*addresses.Address, error) {
+	addressDTO := &dbAddressDTO{}
+	err := r.db.GetContext(ctx, addressDTO, selectAddressQuery+"WHERE id=$1 AND user_id IS NOT NULL", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error getting address: address not found")
+		}
+		return nil, fmt.Errorf("error getting address: %v", err)
+	}
+
+	return addressDTO.newAddress()
+}
+
+func (r *AddressRepository) GetAllByUserId(ctx context.Context, userID int64) ([]*addresses.Address, error) {
+	var addressesDTO []*dbAddressDTO
+	err := r.db.SelectContext(ctx, &addressesDTO, selectAddressQuery+"WHERE user_id=$1 ORDER BY is_default DESC, id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting addresses: %v", err)
+	}
+
+	allAddresses := make([]*addresses.Address, 0, len(addressesDTO))
+	for _, addressDTO := range addressesDTO {
+		address, err := addressDTO.newAddress()
+		if err != nil {
+			return nil, err
+		}
+		allAddresses = append(allAddresses, address)
+	}
+
+	return allAddresses, nil
+}
+
+func (r *AddressRepository) Update(ctx context.Context, address *addresses.Address) (*addresses.Address, error) {
+	stmt, err := r.db.PrepareNamedContext(ctx, `
+		UPDATE addresses
+		SET address=:address, city=:city, postal_code=:postal_code, country=:country, updated_at=:updated_at
+		WHERE id=:id AND user_id=:user_id
+		RETURNING id, user_id, address, city, postal_code, country, is_default, created_at, updated_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %v", err)
+	}
+
+	addressDTO := newAddressDTO(address)
+	err = stmt.GetContext(ctx, addressDTO, addressDTO)
+	if err != nil {
+		return nil, fmt.Errorf("error while updating address: %v", err)
+	}
+
+	return addressDTO.newAddress()
+}
+
+func (r *AddressRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM addresses WHERE id=$1 AND user_id IS NOT NULL", id)
+	if err != nil {
+		return fmt.Errorf("error deleting address: %v", err)
+	}
+
+	return nil
+}
+
+// SetDefault makes the address the only default one of its owner
+func (r *AddressRepository) SetDefault(ctx context.Context, address *addresses.Address) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Clearing first, the partial unique index doesn't allow two defaults even mid-statement
+	_, err = tx.ExecContext(ctx, "UPDATE addresses SET is_default=FALSE WHERE user_id=$1 AND is_default AND id<>$2", address.UserID, address.ID.Get())
+	if err != nil {
+		return fmt.Errorf("error while clearing default address: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "UPDATE addresses SET is_default=TRUE, updated_at=NOW() WHERE id=$1 AND user_id=$2", address.ID.Get(), address.UserID)
+	if err != nil {
+		return fmt.Errorf("error while setting default address: %v", err)
+	}
+	if err = expectAffected(result, errors.New("error while setting default address: address not found")); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing address: %v", err)
+	}
+
+	address.IsDefault = true
+	return nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"bookstore_api/internal/core/domain/authors"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuthorRepository struct {
+	*Database
+}
+
+func NewAuthorRepository(db *Database) *AuthorRepository {
+	return &AuthorRepository{
+		Database: db,
+	}
+}
+
+type dbAuthorDTO struct {
+	ID   int64  `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+	Slug string `db:"slug" json:"slug"`
+}
+
+func (d *dbAuthorDTO) newAuthor() (*authors.Author, error) {
+	author, err := authors.NewAuthor(d.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the stored slug, it is what the author page is found by
+	author.Slug = authors.Slug(d.Slug)
+
+	err = author.Create(d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return author, nil
+}
+
+// bookAuthors scans the json array of authors aggregated with a book, in the order they are credited
+type bookAuthors []*dbAuthorDTO
+
+func (a *bookAuthors) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("unsupported book authors type %T", src)
+	}
+}
+
+func (a bookAuthors) newAuthors() ([]*authors.Author, error) {
+	bookAuthors := make([]*authors.Author, 0, len(a))
+	for _, authorDTO := range a {
+		author, err := authorDTO.newAuthor()
+		if err != nil {
+			return nil, err
+		}
+		bookAuthors = append(bookAuthors, author)
+	}
+	return bookAuthors, nil
+}
+
+// setBookAuthors credits the authors to the book in order, replacing whoever was credited before.
+// Authors are matched by slug and created when they aren't known yet.
+func setBookAuthors(ctx context.Context, tx *sqlx.Tx, bookID int64, credited []*authors.Author) ([]*authors.Author, error) {
+	_, err := tx.ExecContext(ctx, "DELETE FROM bookauthor WHERE book_id=$1", bookID)
+	if err != nil {
+		return nil, fmt.Errorf("error removing book authors: %v", err)
+	}
+
+	saved := make([]*authors.Author, 0, len(credited))
+	for position, author := range credited {
+		// The no-op update makes the existing row come back through RETURNING
+		authorDTO := &dbAuthorDTO{}
+		err = tx.GetContext(ctx, authorDTO, `
+			INSERT INTO authors (name, slug)
+			VALUES ($1, $2)
+			ON CONFLICT (slug)
+			DO UPDATE SET slug = EXCLUDED.slug
+			RETURNING id, name, slug
+		`, author.Name.Get(), author.Slug.Get())
+		if err != nil {
+			return nil, fmt.Errorf("error saving author: %v", err)
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO bookauthor (book_id, author_id, position) VALUES ($1, $2, $3)", bookID, authorDTO.ID, position)
+		if err != nil {
+			return nil, fmt.Errorf("error crediting author: %v", err)
+		}
+
+		savedAuthor, err := authorDTO.newAuthor()
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, savedAuthor)
+	}
+
+	return saved, nil
+}
+
+func (r *AuthorRepository) GetBySlug(ctx context.Context, slug string) (*authors.Author, error) {
+	authorDTO := &dbAuthorDTO{}
+	err := r.db.GetContext(ctx, authorDTO, "SELECT id, name, slug FROM authors WHERE slug=$1", slug)
+	if err != nil {
+		return nil, fmt.Errorf("error getting author: %v", err)
+	}
+
+	return authorDTO.newAuthor()
+}
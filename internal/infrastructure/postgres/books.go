@@ -2,8 +2,14 @@ package postgres
 
 import (
 	"bookstore_api/internal/core/domain/books"
+	"bookstore_api/tools"
 	"context"
+	"database/sql"
 	"fmt"
+	"github.com/jmoiron/sqlx"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -28,10 +34,54 @@ type dbBookDTO struct {
 	Price float64 `db:"price"`
 	Stock int64   `db:"stock"`
 
+	Rating     float64 `db:"rating"`
+	NumReviews int64   `db:"num_reviews"`
+
+	Categories categoryNames `db:"categories"`
+	Authors    bookAuthors   `db:"authors"`
+
+	ISBN        sql.NullString `db:"isbn"`
+	Publisher   sql.NullString `db:"publisher"`
+	PageCount   sql.NullInt64  `db:"page_count"`
+	Language    sql.NullString `db:"language"`
+	PublishedOn *time.Time     `db:"published_on"`
+
 	CreatedAt *time.Time `db:"created_at"`
 	UpdatedAt *time.Time `db:"updated_at"`
 }
 
+// bookColumns leaves out the search vector, it only matters to the database
+const bookColumns = `id, title, slug, cover_image, synopsis, price, stock, isbn, publisher, page_count, language, published_on, created_at, updated_at`
+
+const selectBookColumns = `
+	b.id, b.title, b.slug, b.cover_image, b.synopsis, b.price, b.stock,
+	b.isbn, b.publisher, b.page_count, b.language, b.published_on, b.created_at, b.updated_at,
+	COALESCE(r.rating, 0) AS rating, r.num_reviews, c.categories, a.authors
+`
+
+// joinBookAggregates attaches the aggregated rating of the reviews, the category names and the authors to every book
+const joinBookAggregates = `
+	LEFT JOIN LATERAL (
+		SELECT ROUND(AVG(rating), 2) AS rating, COUNT(*) AS num_reviews
+		FROM reviews
+		WHERE book_id = b.id
+	) r ON TRUE
+	LEFT JOIN LATERAL (
+		SELECT COALESCE(json_agg(c.name ORDER BY c.name), '[]') AS categories
+		FROM bookcategory bc
+		JOIN categories c ON c.id = bc.category_id
+		WHERE bc.book_id = b.id
+	) c ON TRUE
+	LEFT JOIN LATERAL (
+		SELECT COALESCE(json_agg(json_build_object('id', a.id, 'name', a.name, 'slug', a.slug) ORDER BY ba.position), '[]') AS authors
+		FROM bookauthor ba
+		JOIN authors a ON a.id = ba.author_id
+		WHERE ba.book_id = b.id
+	) a ON TRUE
+`
+
+const selectBookQuery = `SELECT ` + selectBookColumns + ` FROM books b ` + joinBookAggregates
+
 func (d *dbBookDTO) newBook(id int64) (*books.Book, error) {
 	book, err := books.NewBook(d.Title, d.CoverImage, d.Synopsis, d.Price, d.Stock)
 	if err != nil {
@@ -42,6 +92,24 @@ func (d *dbBookDTO) newBook(id int64) (*books.Book, error) {
 		book.CreatedAt = d.CreatedAt
 	}
 
+	// The stored slug may carry a collision suffix the title alone wouldn't produce
+	if d.Slug != "" {
+		book.Slug = books.Slug(d.Slug)
+	}
+
+	book.Rating = books.NewRating(d.Rating, d.NumReviews)
+	book.Categories = d.Categories
+
+	book.Details, err = books.NewDetails(d.ISBN.String, d.Publisher.String, d.PageCount.Int64, d.Language.String, d.PublishedOn)
+	if err != nil {
+		return nil, err
+	}
+
+	book.Authors, err = d.Authors.newAuthors()
+	if err != nil {
+		return nil, err
+	}
+
 	err = book.Create(id)
 	if err != nil {
 		return nil, err
@@ -59,24 +127,58 @@ func newBookDTO(book *books.Book) *dbBookDTO {
 		Synopsis:   book.Synopsis.Get(),
 		Price:      book.Price.Get(),
 		Stock:      book.Stock.Get(),
-		CreatedAt:  book.CreatedAt,
-		UpdatedAt:  book.UpdatedAt,
+
+		// Details that aren't known are stored as NULL, an empty ISBN would clash with every other one
+		ISBN:        sql.NullString{String: book.Details.ISBN.Get(), Valid: book.Details.ISBN != ""},
+		Publisher:   sql.NullString{String: book.Details.Publisher.Get(), Valid: book.Details.Publisher != ""},
+		PageCount:   sql.NullInt64{Int64: book.Details.PageCount.Get(), Valid: book.Details.PageCount > 0},
+		Language:    sql.NullString{String: book.Details.Language.Get(), Valid: book.Details.Language != ""},
+		PublishedOn: book.Details.PublishedOn,
+
+		CreatedAt: book.CreatedAt,
+		UpdatedAt: book.UpdatedAt,
+	}
+}
+
+// uniqueSlug suffixes the slug with -2, -3 and so on when another book, or another book's old slug, already has it
+func (r *BookRepository) uniqueSlug(ctx context.Context, q sqlx.QueryerContext, slug string, bookID int64) (string, error) {
+	// Slugs only hold [a-z0-9-], so there is nothing to escape in the LIKE pattern
+	var taken []string
+	err := sqlx.SelectContext(ctx, q, &taken, `
+		SELECT slug FROM books WHERE (slug = $1 OR slug LIKE $2) AND id <> $3
+		UNION
+		SELECT slug FROM bookslughistory WHERE (slug = $1 OR slug LIKE $2) AND book_id <> $3
+	`, slug, slug+"-%", bookID)
+	if err != nil {
+		return "", fmt.Errorf("error checking slug: %v", err)
 	}
+
+	return tools.UniqueSlug(slug, taken), nil
 }
 
 func (r *BookRepository) Create(ctx context.Context, book *books.Book) (*books.Book, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
 	bookDTO := newBookDTO(book)
+	bookDTO.Slug, err = r.uniqueSlug(ctx, tx, bookDTO.Slug, bookDTO.ID)
+	if err != nil {
+		return nil, err
+	}
 
+	// Either the ISBN or, for books without one, the title is taken
 	query := `
-		INSERT INTO books (title, slug, cover_image, synopsis, price, stock) 
-		VALUES (:title, :slug, :cover_image, :synopsis, :price, :stock) 
-		ON CONFLICT (title)
+		INSERT INTO books (title, slug, cover_image, synopsis, price, stock, isbn, publisher, page_count, language, published_on) 
+		VALUES (:title, :slug, :cover_image, :synopsis, :price, :stock, :isbn, :publisher, :page_count, :language, :published_on) 
+		ON CONFLICT
 		DO NOTHING
-		RETURNING *
-    `
+		RETURNING ` + bookColumns
 
 	// Prepare the named query
-	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	stmt, err := tx.PrepareNamedContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("error preparing query: %v", err)
 	}
@@ -85,24 +187,155 @@ func (r *BookRepository) Create(ctx context.Context, book *books.Book) (*books.B
 	err = stmt.GetContext(ctx, bookDTO, bookDTO)
 	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
-			return nil, fmt.Errorf("error while inserting book: book title already exists")
+			return nil, fmt.Errorf("error while inserting book: book title or isbn already exists")
 		}
 		return nil, fmt.Errorf("error while inserting book: %v", err)
 	}
 
-	// This part's kinda sus
+	// The slug belongs to the new book now, a history row for it must not redirect elsewhere
+	_, err = tx.ExecContext(ctx, "DELETE FROM bookslughistory WHERE slug=$1", bookDTO.Slug)
+	if err != nil {
+		return nil, fmt.Errorf("error while recording slug history: %v", err)
+	}
+
 	newBook, err := bookDTO.newBook(bookDTO.ID)
 	if err != nil {
 		return nil, err
 	}
-	//
+
+	newBook.Authors, err = setBookAuthors(ctx, tx, bookDTO.ID, book.Authors)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing book: %v", err)
+	}
 
 	return newBook, nil
 }
 
+// Upsert inserts the book or overwrites the one it is an edition of, the bool reports whether it was inserted.
+// A book is matched by its ISBN first, then by the title of a book without one, which gets the ISBN from then on.
+// Details and authors the book doesn't have are left as they were, and so is the slug so its links keep working.
+func (r *BookRepository) Upsert(ctx context.Context, book *books.Book) (*books.Book, bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	bookDTO := newBookDTO(book)
+
+	var existingIDs []int64
+	err = tx.SelectContext(ctx, &existingIDs, `
+		(SELECT id FROM books WHERE isbn = $1)
+		UNION ALL
+		(SELECT id FROM books WHERE isbn IS NULL AND title = $2)
+		LIMIT 1
+	`, bookDTO.ISBN, bookDTO.Title)
+	if err != nil {
+		return nil, false, fmt.Errorf("error while upserting book: %v", err)
+	}
+
+	var query string
+	inserted := len(existingIDs) == 0
+	if inserted {
+		bookDTO.Slug, err = r.uniqueSlug(ctx, tx, bookDTO.Slug, 0)
+		if err != nil {
+			return nil, false, err
+		}
+
+		query = `
+			INSERT INTO books (title, slug, cover_image, synopsis, price, stock, isbn, publisher, page_count, language, published_on) 
+			VALUES (:title, :slug, :cover_image, :synopsis, :price, :stock, :isbn, :publisher, :page_count, :language, :published_on) 
+			RETURNING ` + bookColumns
+	} else {
+		bookDTO.ID = existingIDs[0]
+		query = `
+			UPDATE books
+			SET title=:title, cover_image=:cover_image, synopsis=:synopsis, price=:price, stock=:stock,
+				isbn=COALESCE(:isbn, isbn), publisher=COALESCE(:publisher, publisher), page_count=COALESCE(:page_count, page_count),
+				language=COALESCE(:language, language), published_on=COALESCE(:published_on, published_on), updated_at=NOW()
+			WHERE id=:id
+			RETURNING ` + bookColumns
+	}
+
+	stmt, err := tx.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, false, fmt.Errorf("error preparing query: %v", err)
+	}
+
+	err = stmt.GetContext(ctx, bookDTO, bookDTO)
+	if err != nil {
+		return nil, false, fmt.Errorf("error while upserting book: %v", err)
+	}
+
+	// Like in Create, the slug of an inserted book must not redirect elsewhere
+	if inserted {
+		_, err = tx.ExecContext(ctx, "DELETE FROM bookslughistory WHERE slug=$1", bookDTO.Slug)
+		if err != nil {
+			return nil, false, fmt.Errorf("error while recording slug history: %v", err)
+		}
+	}
+
+	newBook, err := bookDTO.newBook(bookDTO.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(book.Authors) > 0 {
+		newBook.Authors, err = setBookAuthors(ctx, tx, bookDTO.ID, book.Authors)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, false, fmt.Errorf("error committing book: %v", err)
+	}
+
+	return newBook, inserted, nil
+}
+
+// Stream hands every book to fn in id order, one row at a time rather than collecting them, and stops at fn's first error
+func (r *BookRepository) Stream(ctx context.Context, fn func(book *books.Book) error) error {
+	rows, err := r.db.QueryxContext(ctx, selectBookQuery+"ORDER BY b.id")
+	if err != nil {
+		return fmt.Errorf("error streaming books: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bookDTO := &dbBookDTO{}
+		err = rows.StructScan(bookDTO)
+		if err != nil {
+			return fmt.Errorf("error scanning book: %v", err)
+		}
+
+		book, err := bookDTO.newBook(bookDTO.ID)
+		if err != nil {
+			return err
+		}
+
+		err = fn(book)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error streaming books: %v", err)
+	}
+
+	return nil
+}
+
 func (r *BookRepository) GetById(ctx context.Context, id int64) (*books.Book, error) {
 	book := &dbBookDTO{}
-	err := r.db.GetContext(ctx, book, "SELECT * FROM books WHERE id=$1", id)
+	err := r.db.GetContext(ctx, book, selectBookQuery+"WHERE b.id=$1", id)
 	if err != nil {
 		return nil, fmt.Errorf("error getting book: %v", err)
 	}
@@ -115,70 +348,463 @@ func (r *BookRepository) GetById(ctx context.Context, id int64) (*books.Book, er
 	return newBook, nil
 }
 
-func (r *BookRepository) GetAll(ctx context.Context, page int64) ([]*books.Book, error) {
+func (r *BookRepository) GetBySlug(ctx context.Context, slug string) (*books.Book, error) {
+	book := &dbBookDTO{}
+	err := r.db.GetContext(ctx, book, selectBookQuery+"WHERE b.slug=$1", slug)
+	if err != nil {
+		return nil, fmt.Errorf("error getting book: %w", err)
+	}
+
+	return book.newBook(book.ID)
+}
+
+// GetCurrentSlug follows an old slug to the slug its book has now
+func (r *BookRepository) GetCurrentSlug(ctx context.Context, oldSlug string) (string, error) {
+	var slug string
+	err := r.db.GetContext(ctx, &slug, `
+		SELECT b.slug
+		FROM bookslughistory h
+		JOIN books b ON b.id = h.book_id
+		WHERE h.slug=$1
+	`, oldSlug)
+	if err != nil {
+		return "", fmt.Errorf("error getting slug: %w", err)
+	}
+
+	return slug, nil
+}
+
+func (r *BookRepository) GetAll(ctx context.Context, query *books.ListQuery) (*books.Page, error) {
+	return r.listBooks(ctx, query)
+}
+
+type dbBookSearchDTO struct {
+	dbBookDTO
+
+	Rank              float64 `db:"rank"`
+	TitleHighlight    string  `db:"title_highlight"`
+	SynopsisHighlight string  `db:"synopsis_highlight"`
+}
+
+// Search matches the query against titles and synopses, best matches first
+func (r *BookRepository) Search(ctx context.Context, query string, page int64) ([]*books.SearchResult, error) {
 	limit := 20
 	offset := limit * (int(page) - 1)
 
-	query := `
-		SELECT * 
-		FROM books 
-		LIMIT $1 
-		OFFSET $2
+	// websearch_to_tsquery never fails on user input, quotes and "or" work like they do in a search engine
+	searchQuery := `SELECT ` + selectBookColumns + `,
+			ts_rank(b.search_vector, q) AS rank,
+			ts_headline('english', ` + escapeHTML("b.title") + `, q, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>') AS title_highlight,
+			ts_headline('english', ` + escapeHTML("COALESCE(b.synopsis, '')") + `, q, 'MaxFragments=2, MaxWords=30, MinWords=10, StartSel=<mark>, StopSel=</mark>') AS synopsis_highlight
+		FROM books b
+		CROSS JOIN websearch_to_tsquery('english', $1) q
+		` + joinBookAggregates + `
+		WHERE b.search_vector @@ q
+		ORDER BY rank DESC, b.id
+		LIMIT $2
+		OFFSET $3
 	`
 
+	var resultsDTO []*dbBookSearchDTO
+	err := r.db.SelectContext(ctx, &resultsDTO, searchQuery, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error searching books: %v", err)
+	}
+
+	results := make([]*books.SearchResult, 0, len(resultsDTO))
+	for _, result := range resultsDTO {
+		book, err := result.newBook(result.ID)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &books.SearchResult{
+			Book:              book,
+			Rank:              result.Rank,
+			TitleHighlight:    result.TitleHighlight,
+			SynopsisHighlight: result.SynopsisHighlight,
+		})
+	}
+
+	return results, nil
+}
+
+// escapeHTML escapes the markup in a text expression, the <mark> tags ts_headline adds are then the only tags in a highlight
+func escapeHTML(expression string) string {
+	return `replace(replace(replace(replace(` + expression + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;')`
+}
+
+// bookSortColumn is how a sort option is ordered by and compared against a cursor
+type bookSortColumn struct {
+	column string
+	cast   string
+	value  func(*dbBookDTO) string
+}
+
+var bookSortColumns = map[books.SortField]bookSortColumn{
+	books.SortCreatedAt: {column: "created_at", cast: "timestamp", value: func(d *dbBookDTO) string {
+		if d.CreatedAt == nil {
+			return time.Time{}.Format(time.RFC3339Nano)
+		}
+		return d.CreatedAt.Format(time.RFC3339Nano)
+	}},
+	books.SortPrice: {column: "price", cast: "numeric", value: func(d *dbBookDTO) string {
+		return strconv.FormatFloat(d.Price, 'f', -1, 64)
+	}},
+	books.SortTitle: {column: "title", cast: "text", value: func(d *dbBookDTO) string {
+		return d.Title
+	}},
+	books.SortRating: {column: "rating", cast: "numeric", value: func(d *dbBookDTO) string {
+		return strconv.FormatFloat(d.Rating, 'f', -1, 64)
+	}},
+}
+
+// sqlConditions collects WHERE clauses, every value goes in as a bind argument and never into the SQL itself
+type sqlConditions struct {
+	clauses []string
+	args    []any
+}
+
+// add appends the clause, each ? in it is bound to the next value
+func (c *sqlConditions) add(clause string, values ...any) {
+	for _, value := range values {
+		c.args = append(c.args, value)
+		clause = strings.Replace(clause, "?", fmt.Sprintf("$%d", len(c.args)), 1)
+	}
+	c.clauses = append(c.clauses, clause)
+}
+
+func (c *sqlConditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// bookFilterConditions turns the filter into conditions on the book b, skipping the filters a facet is counted over
+func bookFilterConditions(filter books.Filter, skip string) *sqlConditions {
+	conditions := &sqlConditions{}
+
+	if skip != "price" {
+		if filter.MinPrice != nil {
+			conditions.add("b.price >= ?", filter.MinPrice.Get())
+		}
+		if filter.MaxPrice != nil {
+			conditions.add("b.price <= ?", filter.MaxPrice.Get())
+		}
+	}
+	if skip != "stock" && filter.InStock {
+		conditions.add("b.stock > 0")
+	}
+	if filter.CreatedAfter != nil {
+		conditions.add("b.created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		conditions.add("b.created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.Author != "" {
+		conditions.add(`EXISTS (
+			SELECT 1
+			FROM bookauthor ba
+			JOIN authors a ON a.id = ba.author_id
+			WHERE ba.book_id = b.id AND a.slug = ?
+		)`, filter.Author)
+	}
+	if skip != "category" && filter.Category != "" {
+		conditions.add(`EXISTS (
+			SELECT 1
+			FROM bookcategory bc
+			JOIN categories c ON c.id = bc.category_id
+			WHERE bc.book_id = b.id AND c.slug = ?
+		)`, filter.Category)
+	}
+
+	return conditions
+}
+
+// listBooks pages through the books matching the filter with keyset pagination
+func (r *BookRepository) listBooks(ctx context.Context, query *books.ListQuery) (*books.Page, error) {
+	sort, ok := bookSortColumns[query.Sort]
+	if !ok {
+		return nil, books.ErrInvalidSort
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	page := &books.Page{}
+	conditions := bookFilterConditions(query.Filter, "")
+	err := r.db.GetContext(ctx, &page.Total, "SELECT COUNT(*) FROM books b"+conditions.where(), conditions.args...)
+	if err != nil {
+		return nil, fmt.Errorf("error counting books: %v", err)
+	}
+
+	page.Facets, err = r.facets(ctx, query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor *tools.Cursor
+	if query.Cursor != "" {
+		cursor, err = tools.DecodeCursor(query.Cursor, query.Sort.Get(), query.Descending)
+		if err != nil {
+			return nil, err
+		}
+		if !validCursorValue(sort.cast, cursor.Value) {
+			return nil, tools.ErrInvalidCursor
+		}
+	}
+
+	// Going back a page walks the listing the other way round, the rows get flipped afterwards
+	before := cursor != nil && cursor.Before
+	ascending := query.Descending == before
+	operator, direction := ">", "ASC"
+	if !ascending {
+		operator, direction = "<", "DESC"
+	}
+
+	if cursor != nil {
+		conditions.add(fmt.Sprintf("(b.%s, b.id) %s (CAST(? AS %s), ?)", sort.column, operator, sort.cast), cursor.Value, cursor.ID)
+	}
+
+	args := append(conditions.args, limit+1)
+	listQuery := "SELECT * FROM (" + selectBookQuery + ") b" + conditions.where() +
+		fmt.Sprintf(" ORDER BY b.%s %s, b.id %s LIMIT $%d", sort.column, direction, direction, len(args))
+
 	var booksDTO []*dbBookDTO
-	err := r.db.SelectContext(ctx, &booksDTO, query, limit, offset)
+	err = r.db.SelectContext(ctx, &booksDTO, listQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error getting books: %v", err)
 	}
 
-	var allBooks []*books.Book
+	// The extra row only tells whether there is more past this page
+	more := len(booksDTO) > limit
+	if more {
+		booksDTO = booksDTO[:limit]
+	}
+	if before {
+		slices.Reverse(booksDTO)
+	}
+
+	page.Books = make([]*books.Book, 0, len(booksDTO))
 	for _, book := range booksDTO {
 		newBook, err := book.newBook(book.ID)
 		if err != nil {
 			return nil, err
 		}
-		allBooks = append(allBooks, newBook)
+		page.Books = append(page.Books, newBook)
+	}
+
+	if len(booksDTO) == 0 {
+		return page, nil
+	}
+
+	cursorAt := func(d *dbBookDTO, before bool) string {
+		c := &tools.Cursor{Sort: query.Sort.Get(), Descending: query.Descending, Value: sort.value(d), ID: d.ID, Before: before}
+		return c.Encode()
+	}
+
+	hasNext, hasPrev := more, cursor != nil
+	if before {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		page.NextCursor = cursorAt(booksDTO[len(booksDTO)-1], false)
+	}
+	if hasPrev {
+		page.PrevCursor = cursorAt(booksDTO[0], true)
 	}
 
-	return allBooks, nil
+	return page, nil
 }
 
+// facets counts every facet with the other filters applied, so picking a facet never empties its siblings
+func (r *BookRepository) facets(ctx context.Context, filter books.Filter) (books.Facets, error) {
+	facets := books.Facets{}
+
+	// The band limits are our own constants, they are bound all the same. Every limit is bound, the lowest included,
+	// so bucket i+1 is band i and only prices below the lowest limit would land in bucket 0.
+	conditions := bookFilterConditions(filter, "price")
+	limits := books.PriceBandLimits
+	bucketArgs := make([]string, 0, len(limits))
+	args := conditions.args
+	for _, limit := range limits {
+		args = append(args, limit)
+		bucketArgs = append(bucketArgs, fmt.Sprintf("CAST($%d AS numeric)", len(args)))
+	}
+
+	var bands []struct {
+		Band  int   `db:"band"`
+		Count int64 `db:"count"`
+	}
+	bandQuery := "SELECT width_bucket(b.price, ARRAY[" + strings.Join(bucketArgs, ", ") + "]) AS band, COUNT(*) AS count FROM books b" +
+		conditions.where() + " GROUP BY band"
+	err := r.db.SelectContext(ctx, &bands, bandQuery, args...)
+	if err != nil {
+		return facets, fmt.Errorf("error counting price bands: %v", err)
+	}
+
+	facets.PriceBands = make([]books.PriceBand, len(limits))
+	for i, limit := range limits {
+		facets.PriceBands[i].Min = limit
+		if i+1 < len(limits) {
+			facets.PriceBands[i].Max = &limits[i+1]
+		}
+	}
+	for _, band := range bands {
+		// Bucket 0 is below the lowest limit, which prices can't be
+		if band.Band > 0 && band.Band <= len(limits) {
+			facets.PriceBands[band.Band-1].Count += band.Count
+		}
+	}
+
+	conditions = bookFilterConditions(filter, "stock")
+	conditions.add("b.stock > 0")
+	err = r.db.GetContext(ctx, &facets.InStock, "SELECT COUNT(*) FROM books b"+conditions.where(), conditions.args...)
+	if err != nil {
+		return facets, fmt.Errorf("error counting books in stock: %v", err)
+	}
+
+	conditions = bookFilterConditions(filter, "category")
+	var categoriesDTO []struct {
+		Slug  string `db:"slug"`
+		Name  string `db:"name"`
+		Count int64  `db:"count"`
+	}
+	err = r.db.SelectContext(ctx, &categoriesDTO, `
+		SELECT c.slug, c.name, COUNT(*) AS count
+		FROM books b
+		JOIN bookcategory bc ON bc.book_id = b.id
+		JOIN categories c ON c.id = bc.category_id
+	`+conditions.where()+" GROUP BY c.slug, c.name ORDER BY c.name", conditions.args...)
+	if err != nil {
+		return facets, fmt.Errorf("error counting categories: %v", err)
+	}
+
+	facets.Categories = make([]books.CategoryCount, 0, len(categoriesDTO))
+	for _, category := range categoriesDTO {
+		facets.Categories = append(facets.Categories, books.CategoryCount{
+			Slug:  category.Slug,
+			Name:  category.Name,
+			Count: category.Count,
+		})
+	}
+
+	return facets, nil
+}
+
+// validCursorValue keeps a tampered cursor from reaching the database as a failing cast
+func validCursorValue(cast string, value string) bool {
+	switch cast {
+	case "numeric":
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	case "timestamp":
+		_, err := time.Parse(time.RFC3339Nano, value)
+		return err == nil
+	default:
+		return true
+	}
+}
+
+// Update saves the book, a slug replaced by a new title goes to the history so it keeps resolving
 func (r *BookRepository) Update(ctx context.Context, book *books.Book) (*books.Book, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var oldSlug sql.NullString
+	err = tx.GetContext(ctx, &oldSlug, "SELECT slug FROM books WHERE id=$1 FOR UPDATE", book.ID.Get())
+	if err != nil {
+		return nil, fmt.Errorf("error while updating book: %v", err)
+	}
+
 	query := `
 		WITH title_conflict AS (
-			SELECT id FROM books WHERE title = :title AND NOT id = :id
+			SELECT id FROM books
+			WHERE NOT id = :id AND (isbn = :isbn OR (isbn IS NULL AND CAST(:isbn AS VARCHAR) IS NULL AND title = :title))
 		)
 		UPDATE books
-		SET title=:title, slug=:slug, cover_image=:cover_image, synopsis=:synopsis, price=:price, stock=:stock, updated_at=:updated_at 
+		SET title=:title, slug=:slug, cover_image=:cover_image, synopsis=:synopsis, price=:price, stock=:stock,
+			isbn=:isbn, publisher=:publisher, page_count=:page_count, language=:language, published_on=:published_on, updated_at=:updated_at 
 		WHERE id=:id AND NOT EXISTS (SELECT 1 FROM title_conflict)
-		RETURNING *
-	`
+		RETURNING ` + bookColumns
 
 	// Use NamedQueryRowContext for queries that return a single row.
-	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	stmt, err := tx.PrepareNamedContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("error preparing query: %v", err)
 	}
 
 	// Execute the query and map the result to updatedBook.
 	bookDTO := newBookDTO(book)
+	bookDTO.Slug, err = r.uniqueSlug(ctx, tx, bookDTO.Slug, bookDTO.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	err = stmt.GetContext(ctx, bookDTO, bookDTO)
 	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
-			return nil, fmt.Errorf("error while updating book: book title already exists")
+			return nil, fmt.Errorf("error while updating book: book title or isbn already exists")
 		}
 		return nil, fmt.Errorf("error while updating book: %v", err)
 	}
 
+	if oldSlug.Valid && oldSlug.String != "" && oldSlug.String != bookDTO.Slug {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO bookslughistory (slug, book_id)
+			VALUES ($1, $2)
+			ON CONFLICT (slug)
+			DO UPDATE SET book_id = EXCLUDED.book_id, created_at = NOW()
+		`, oldSlug.String, bookDTO.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error while recording slug history: %v", err)
+		}
+	}
+
+	// A slug that is current again, like after renaming a book back, must not redirect anymore
+	_, err = tx.ExecContext(ctx, "DELETE FROM bookslughistory WHERE slug=$1", bookDTO.Slug)
+	if err != nil {
+		return nil, fmt.Errorf("error while recording slug history: %v", err)
+	}
+
 	newBook, err := bookDTO.newBook(book.ID.Get())
 	if err != nil {
 		return nil, err
 	}
 
+	newBook.Authors, err = setBookAuthors(ctx, tx, bookDTO.ID, book.Authors)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing book: %v", err)
+	}
+
 	return newBook, nil
 }
 
+func (r *BookRepository) UpdateCover(ctx context.Context, id int64, from string, to string) (bool, error) {
+	// Re-encrypting isn't an edit of the book, updated_at stays as it is
+	result, err := r.db.ExecContext(ctx, "UPDATE books SET cover_image=$3 WHERE id=$1 AND cover_image=$2", id, from, to)
+	if err != nil {
+		return false, fmt.Errorf("error updating cover: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error updating cover: %v", err)
+	}
+
+	return affected == 1, nil
+}
+
 func (r *BookRepository) Delete(ctx context.Context, id int64) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM books WHERE id=$1", id)
 	if err != nil {
@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"bookstore_api/internal/core/domain/categories"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type CategoryRepository struct {
+	*Database
+}
+
+func NewCategoryRepository(db *Database) *CategoryRepository {
+	return &CategoryRepository{
+		Database: db,
+	}
+}
+
+type dbCategoryDTO struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+	Slug string `db:"slug"`
+}
+
+func (d *dbCategoryDTO) newCategory() (*categories.Category, error) {
+	category, err := categories.NewCategory(d.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the stored slug, it is what the catalog is filtered by
+	category.Slug = categories.Slug(d.Slug)
+
+	err = category.Create(d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func newCategoryDTO(category *categories.Category) *dbCategoryDTO {
+	return &dbCategoryDTO{
+		ID:   category.ID.Get(),
+		Name: category.Name.Get(),
+		Slug: category.Slug.Get(),
+	}
+}
+
+// categoryNames scans the json array of category names aggregated with a book
+type categoryNames []string
+
+func (c *categoryNames) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("unsupported category names type %T", src)
+	}
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, category *categories.Category) (*categories.Category, error) {
+	query := `
+		INSERT INTO categories (name, slug)
+		VALUES (:name, :slug)
+		ON CONFLICT
+		DO NOTHING
+		RETURNING id, name, slug
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %v", err)
+	}
+
+	categoryDTO := newCategoryDTO(category)
+	err = stmt.GetContext(ctx, categoryDTO, categoryDTO)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error while inserting category: category already exists")
+		}
+		return nil, fmt.Errorf("error while inserting category: %v", err)
+	}
+
+	return categoryDTO.newCategory()
+}
+
+func (r *CategoryRepository) GetById(ctx context.Context, id int64) (*categories.Category, error) {
+	categoryDTO := &dbCategoryDTO{}
+	err := r.db.GetContext(ctx, categoryDTO, "SELECT id, name, slug FROM categories WHERE id=$1", id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting category: %v", err)
+	}
+
+	return categoryDTO.newCategory()
+}
+
+func (r *CategoryRepository) GetAll(ctx context.Context) ([]*categories.Category, error) {
+	var categoriesDTO []*dbCategoryDTO
+	err := r.db.SelectContext(ctx, &categoriesDTO, "SELECT id, name, slug FROM categories ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("error getting categories: %v", err)
+	}
+
+	allCategories := make([]*categories.Category, 0, len(categoriesDTO))
+	for _, categoryDTO := range categoriesDTO {
+		category, err := categoryDTO.newCategory()
+		if err != nil {
+			return nil, err
+		}
+		allCategories = append(allCategories, category)
+	}
+
+	return allCategories, nil
+}
+
+func (r *CategoryRepository) Update(ctx context.Context, category *categories.Category) (*categories.Category, error) {
+	query := `
+		WITH name_conflict AS (
+			SELECT id FROM categories WHERE (name = :name OR slug = :slug) AND NOT id = :id
+		)
+		UPDATE categories
+		SET name=:name, slug=:slug
+		WHERE id=:id AND NOT EXISTS (SELECT 1 FROM name_conflict)
+		RETURNING id, name, slug
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %v", err)
+	}
+
+	categoryDTO := newCategoryDTO(category)
+	err = stmt.GetContext(ctx, categoryDTO, categoryDTO)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error while updating category: category already exists")
+		}
+		return nil, fmt.Errorf("error while updating category: %v", err)
+	}
+
+	return categoryDTO.newCategory()
+}
+
+func (r *CategoryRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM categories WHERE id=$1", id)
+	if err != nil {
+		return fmt.Errorf("error deleting category: %v", err)
+	}
+
+	return nil
+}
+
+// AttachBook puts the book in the category, attaching it twice is a no-op
+func (r *CategoryRepository) AttachBook(ctx context.Context, bookID int64, categoryID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO bookcategory (book_id, category_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, bookID, categoryID)
+	if err != nil {
+		return fmt.Errorf("error attaching category: %v", err)
+	}
+
+	return nil
+}
+
+func (r *CategoryRepository) DetachBook(ctx context.Context, bookID int64, categoryID int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM bookcategory WHERE book_id=$1 AND category_id=$2", bookID, categoryID)
+	if err != nil {
+		return fmt.Errorf("error detaching category: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,317 @@
+package postgres
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/payments"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type OrderRepository struct {
+	*Database
+}
+
+func NewOrderRepository(db *Database) *OrderRepository {
+	return &OrderRepository{
+		Database: db,
+	}
+}
+
+type dbOrderDTO struct {
+	ID        int64 `db:"id"`
+	UserID    int64 `db:"user_id"`
+	AddressID int64 `db:"address_id"`
+
+	Address    string `db:"address"`
+	City       string `db:"city"`
+	PostalCode string `db:"postal_code"`
+	Country    string `db:"country"`
+
+	ProductPrice float64 `db:"product_price"`
+	TaxFee       float64 `db:"tax_fee"`
+	TotalPrice   float64 `db:"total_price"`
+
+	PaymentMethod string `db:"payment_method"`
+	Status        string `db:"status"`
+
+	CreatedAt *time.Time `db:"created_at"`
+	UpdatedAt *time.Time `db:"updated_at"`
+}
+
+type dbOrderLineDTO struct {
+	ID        int64   `db:"id"`
+	BookID    int64   `db:"book_id"`
+	Quantity  int64   `db:"book_quantity"`
+	UnitPrice float64 `db:"unit_price"`
+}
+
+func (d *dbOrderDTO) newOrder(lines []*dbOrderLineDTO) (*orders.Order, error) {
+	// Snapshots are rebuilt as they were stored, they predate any validation rule that came later
+	address := &addresses.Address{
+		ID:         addresses.ID(d.AddressID),
+		Address:    d.Address,
+		City:       d.City,
+		PostalCode: addresses.PostalCode(d.PostalCode),
+		Country:    addresses.Country(d.Country),
+	}
+
+	order, err := orders.NewOrder(d.UserID, address, d.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	order.Status, err = orders.NewStatus(d.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range lines {
+		quantity, err := orders.NewQuantity(l.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		unitPrice, err := orders.NewAmount(l.UnitPrice)
+		if err != nil {
+			return nil, err
+		}
+		order.Lines = append(order.Lines, &orders.Line{
+			ID:        orders.ID(l.ID),
+			BookID:    l.BookID,
+			Quantity:  quantity,
+			UnitPrice: unitPrice,
+		})
+	}
+
+	// Stored amounts win over recalculating, the tax rate might have changed since
+	if order.ProductPrice, err = orders.NewAmount(d.ProductPrice); err != nil {
+		return nil, err
+	}
+	if order.TaxFee, err = orders.NewAmount(d.TaxFee); err != nil {
+		return nil, err
+	}
+	if order.TotalPrice, err = orders.NewAmount(d.TotalPrice); err != nil {
+		return nil, err
+	}
+
+	order.CreatedAt = d.CreatedAt
+	order.UpdatedAt = d.UpdatedAt
+
+	err = order.Create(d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// Create reserves the stock, then writes the address, the order and every order line in a single transaction
+func (r *OrderRepository) Create(ctx context.Context, order *orders.Order) (*orders.Order, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = reserveStock(ctx, tx, order.Lines)
+	if err != nil {
+		return nil, err
+	}
+
+	// The address is stored as a snapshot without an owner, editing the address book won't rewrite past orders
+	address := order.Address
+	var addressID int64
+	err = tx.GetContext(ctx, &addressID, `
+		INSERT INTO addresses (address, city, postal_code, country)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, address.Address, address.City, address.PostalCode.Get(), address.Country.Get())
+	if err != nil {
+		return nil, fmt.Errorf("error while inserting address: %v", err)
+	}
+	address.ID = addresses.ID(addressID)
+
+	var orderID int64
+	var createdAt time.Time
+	err = tx.QueryRowxContext(ctx, `
+		INSERT INTO orders (user_id, address_id, product_price, tax_fee, total_price, payment_method)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, order.UserID, addressID, order.ProductPrice.Get(), order.TaxFee.Get(), order.TotalPrice.Get(), order.PaymentMethod.Get()).
+		Scan(&orderID, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("error while inserting order: %v", err)
+	}
+
+	for _, line := range order.Lines {
+		var lineID int64
+		err = tx.GetContext(ctx, &lineID, `
+			INSERT INTO orderbooks (order_id, book_id, book_quantity, unit_price)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`, orderID, line.BookID, line.Quantity.Get(), line.UnitPrice.Get())
+		if err != nil {
+			return nil, fmt.Errorf("error while inserting order line: %v", err)
+		}
+		line.ID = orders.ID(lineID)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing order: %v", err)
+	}
+
+	order.CreatedAt = &createdAt
+	err = order.Create(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (r *OrderRepository) GetById(ctx context.Context, id int64) (*orders.Order, error) {
+	orderDTO := &dbOrderDTO{}
+	err := r.db.GetContext(ctx, orderDTO, `
+		SELECT o.id, o.user_id, o.address_id, a.address, a.city, a.postal_code, a.country,
+		       o.product_price, o.tax_fee, o.total_price, o.payment_method, o.status, o.created_at, o.updated_at
+		FROM orders o
+		JOIN addresses a ON a.id = o.address_id
+		WHERE o.id = $1
+	`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error getting order: order not found")
+		}
+		return nil, fmt.Errorf("error getting order: %v", err)
+	}
+
+	var linesDTO []*dbOrderLineDTO
+	err = r.db.SelectContext(ctx, &linesDTO, `
+		SELECT id, book_id, book_quantity, unit_price
+		FROM orderbooks
+		WHERE order_id = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting order lines: %v", err)
+	}
+
+	return orderDTO.newOrder(linesDTO)
+}
+
+// Cancel marks a placed order as canceled and puts the reserved stock back, in a single transaction
+func (r *OrderRepository) Cancel(ctx context.Context, order *orders.Order) (*orders.Order, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The status check guards against two cancellations releasing the stock twice
+	result, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET status=$1, updated_at=$2
+		WHERE id=$3 AND status=$4
+	`, orders.Canceled.Get(), order.UpdatedAt, order.ID.Get(), orders.Placed.Get())
+	if err != nil {
+		return nil, fmt.Errorf("error while canceling order: %v", err)
+	}
+	if err = expectAffected(result, orders.ErrNotCancelable); err != nil {
+		return nil, err
+	}
+
+	// A pending payment goes down with its order, a completed event arriving later is then recorded without being applied
+	_, err = tx.ExecContext(ctx, `
+		UPDATE paymentresults p
+		SET status=$1, updated_at=$2, canceled_at=$2
+		FROM orders o
+		WHERE o.id=$3 AND o.payment_result_id = p.id AND p.status=$4
+	`, payments.Canceled.Get(), order.UpdatedAt, order.ID.Get(), payments.Pending.Get())
+	if err != nil {
+		return nil, fmt.Errorf("error while canceling payment: %v", err)
+	}
+
+	err = releaseStock(ctx, tx, order.ID.Get())
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing order: %v", err)
+	}
+
+	return order, nil
+}
+
+// reserveStock locks the books of the order and decrements their stock.
+// Every short line item is reported at once, rather than failing on the first one.
+func reserveStock(ctx context.Context, tx *sqlx.Tx, lines []*orders.Line) error {
+	bookIDs := make([]int64, 0, len(lines))
+	for _, line := range lines {
+		bookIDs = append(bookIDs, line.BookID)
+	}
+
+	// Rows are locked in id order so concurrent checkouts can't deadlock each other
+	query, args, err := sqlx.In(`SELECT id, COALESCE(stock, 0) AS stock FROM books WHERE id IN (?) ORDER BY id FOR UPDATE`, bookIDs)
+	if err != nil {
+		return fmt.Errorf("error building stock query: %v", err)
+	}
+
+	var stocks []struct {
+		ID    int64 `db:"id"`
+		Stock int64 `db:"stock"`
+	}
+	err = tx.SelectContext(ctx, &stocks, tx.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("error locking stock: %v", err)
+	}
+
+	available := make(map[int64]int64, len(stocks))
+	for _, s := range stocks {
+		available[s.ID] = s.Stock
+	}
+
+	shortage := &orders.InsufficientStockError{}
+	for _, line := range lines {
+		if available[line.BookID] < line.Quantity.Get() {
+			shortage.Shortages = append(shortage.Shortages, orders.StockShortage{
+				BookID:    line.BookID,
+				Requested: line.Quantity.Get(),
+				Available: available[line.BookID],
+			})
+		}
+	}
+	if len(shortage.Shortages) > 0 {
+		return shortage
+	}
+
+	for _, line := range lines {
+		_, err = tx.ExecContext(ctx, "UPDATE books SET stock = stock - $1 WHERE id = $2", line.Quantity.Get(), line.BookID)
+		if err != nil {
+			return fmt.Errorf("error reserving stock: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// releaseStock puts the quantity of every line of the order back into the books stock
+func releaseStock(ctx context.Context, tx *sqlx.Tx, orderID int64) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE books b
+		SET stock = b.stock + ob.book_quantity
+		FROM orderbooks ob
+		WHERE ob.order_id = $1 AND b.id = ob.book_id
+	`, orderID)
+	if err != nil {
+		return fmt.Errorf("error releasing stock: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+package postgres
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/payments"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type PaymentRepository struct {
+	*Database
+}
+
+func NewPaymentRepository(db *Database) *PaymentRepository {
+	return &PaymentRepository{
+		Database: db,
+	}
+}
+
+type dbPaymentDTO struct {
+	ID      int64 `db:"id"`
+	OrderID int64 `db:"order_id"`
+	UserID  int64 `db:"user_id"`
+
+	PaymentMethod     string  `db:"payment_method"`
+	Amount            float64 `db:"amount"`
+	Status            string  `db:"status"`
+	EmailAddress      string  `db:"email_address"`
+	ProviderReference string  `db:"provider_reference"`
+
+	CreatedAt   *time.Time `db:"created_at"`
+	UpdatedAt   *time.Time `db:"updated_at"`
+	CompletedAt *time.Time `db:"completed_at"`
+	CanceledAt  *time.Time `db:"canceled_at"`
+}
+
+func (d *dbPaymentDTO) newPayment() (*payments.Payment, error) {
+	method, err := orders.NewPaymentMethod(d.PaymentMethod)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := orders.NewAmount(d.Amount)
+	if err != nil {
+		return nil, err
+	}
+	status, err := payments.NewStatus(d.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &payments.Payment{
+		OrderID:           d.OrderID,
+		UserID:            d.UserID,
+		Method:            method,
+		Amount:            amount,
+		Status:            status,
+		EmailAddress:      d.EmailAddress,
+		ProviderReference: d.ProviderReference,
+		CreatedAt:         d.CreatedAt,
+		UpdatedAt:         d.UpdatedAt,
+		CompletedAt:       d.CompletedAt,
+		CanceledAt:        d.CanceledAt,
+	}
+
+	err = payment.Create(d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+const selectPaymentQuery = `
+	SELECT p.id, o.id AS order_id, o.user_id, p.payment_method, p.amount, p.status,
+	       COALESCE(p.email_address, '') AS email_address, COALESCE(p.provider_reference, '') AS provider_reference,
+	       p.created_at, p.updated_at, p.completed_at, p.canceled_at
+	FROM paymentresults p
+	JOIN orders o ON o.payment_result_id = p.id
+`
+
+// Create inserts a pending payment and links it to its order in a single transaction
+func (r *PaymentRepository) Create(ctx context.Context, payment *payments.Payment) (*payments.Payment, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var paymentID int64
+	var createdAt time.Time
+	err = tx.QueryRowxContext(ctx, `
+		INSERT INTO paymentresults (status, email_address, payment_method, amount, provider_reference)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, payment.Status.Get(), payment.EmailAddress, payment.Method.Get(), payment.Amount.Get(), payment.ProviderReference).
+		Scan(&paymentID, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("error while inserting payment: %v", err)
+	}
+
+	// Only a placed order without a payment yet can be linked
+	result, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET payment_result_id=$1, updated_at=$2
+		WHERE id=$3 AND payment_result_id IS NULL AND status=$4
+	`, paymentID, createdAt, payment.OrderID, orders.Placed.Get())
+	if err != nil {
+		return nil, fmt.Errorf("error while linking payment: %v", err)
+	}
+	if err = expectAffected(result, payments.ErrOrderNotPayable); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing payment: %v", err)
+	}
+
+	payment.CreatedAt = &createdAt
+	err = payment.Create(paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (r *PaymentRepository) GetById(ctx context.Context, id int64) (*payments.Payment, error) {
+	return r.get(ctx, selectPaymentQuery+"WHERE p.id = $1", id)
+}
+
+func (r *PaymentRepository) GetByOrderId(ctx context.Context, orderID int64) (*payments.Payment, error) {
+	return r.get(ctx, selectPaymentQuery+"WHERE o.id = $1", orderID)
+}
+
+func (r *PaymentRepository) get(ctx context.Context, query string, args ...any) (*payments.Payment, error) {
+	paymentDTO := &dbPaymentDTO{}
+	err := r.db.GetContext(ctx, paymentDTO, query, args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error getting payment: payment not found")
+		}
+		return nil, fmt.Errorf("error getting payment: %v", err)
+	}
+
+	return paymentDTO.newPayment()
+}
+
+// Complete marks a pending payment as completed and its order as paid.
+// capture runs once both rows are locked by the transaction, so no money moves for an order that can't be paid anymore.
+func (r *PaymentRepository) Complete(ctx context.Context, payment *payments.Payment, capture func(context.Context) error) (*payments.Payment, error) {
+	return r.transition(ctx, payment, func(ctx context.Context, tx *sqlx.Tx, payment *payments.Payment) error {
+		err := completePayment(ctx, tx, payment)
+		if err != nil {
+			return err
+		}
+		return capture(ctx)
+	})
+}
+
+// Cancel marks a pending payment as canceled, cancels its order and releases the reserved stock.
+// Like capture in Complete, void only runs once the payment row is locked as still pending.
+func (r *PaymentRepository) Cancel(ctx context.Context, payment *payments.Payment, void func(context.Context) error) (*payments.Payment, error) {
+	return r.transition(ctx, payment, func(ctx context.Context, tx *sqlx.Tx, payment *payments.Payment) error {
+		err := cancelPayment(ctx, tx, payment)
+		if err != nil {
+			return err
+		}
+		return void(ctx)
+	})
+}
+
+func (r *PaymentRepository) GetByProviderReference(ctx context.Context, reference string) (*payments.Payment, error) {
+	return r.get(ctx, selectPaymentQuery+"WHERE p.provider_reference = $1", reference)
+}
+
+// RecordEvent inserts the provider event and applies the payment transition in the same transaction,
+// a redelivered event hits the primary key and is skipped without touching the payment
+func (r *PaymentRepository) RecordEvent(ctx context.Context, event *payments.Event, payment *payments.Payment, changed bool) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO paymentevents (provider, event_id, payment_result_id, status, applied)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, event_id) DO NOTHING
+	`, event.Provider.Get(), event.ID, payment.ID.Get(), event.Status.Get(), changed)
+	if err != nil {
+		return false, fmt.Errorf("error while recording event: %v", err)
+	}
+	recorded, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error while recording event: %v", err)
+	}
+	if recorded == 0 {
+		return false, nil
+	}
+
+	if changed {
+		switch payment.Status {
+		case payments.Completed:
+			err = completePayment(ctx, tx, payment)
+		case payments.Canceled:
+			err = cancelPayment(ctx, tx, payment)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("error committing event: %v", err)
+	}
+
+	return true, nil
+}
+
+func (r *PaymentRepository) transition(ctx context.Context, payment *payments.Payment, fn func(context.Context, *sqlx.Tx, *payments.Payment) error) (*payments.Payment, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = fn(ctx, tx, payment)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing payment: %v", err)
+	}
+
+	return payment, nil
+}
+
+// completePayment writes the completed payment and marks its order as paid,
+// an order canceled in the meantime rolls the whole transition back
+func completePayment(ctx context.Context, tx *sqlx.Tx, payment *payments.Payment) error {
+	err := transitionPayment(ctx, tx, payment)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET status=$1, updated_at=$2
+		WHERE id=$3 AND status=$4
+	`, orders.Paid.Get(), payment.UpdatedAt, payment.OrderID, orders.Placed.Get())
+	if err != nil {
+		return fmt.Errorf("error while updating order: %v", err)
+	}
+
+	return expectAffected(result, payments.ErrOrderNotPayable)
+}
+
+// cancelPayment writes the canceled payment, cancels its order and releases the reserved stock
+func cancelPayment(ctx context.Context, tx *sqlx.Tx, payment *payments.Payment) error {
+	err := transitionPayment(ctx, tx, payment)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET status=$1, updated_at=$2
+		WHERE id=$3 AND status=$4
+	`, orders.Canceled.Get(), payment.UpdatedAt, payment.OrderID, orders.Placed.Get())
+	if err != nil {
+		return fmt.Errorf("error while canceling order: %v", err)
+	}
+
+	// The order might have been canceled on its own already, the stock is back then
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while canceling order: %v", err)
+	}
+	if affected > 0 {
+		return releaseStock(ctx, tx, payment.OrderID)
+	}
+
+	return nil
+}
+
+// transitionPayment writes the new status of a payment, only if it is still pending in the database
+func transitionPayment(ctx context.Context, tx *sqlx.Tx, payment *payments.Payment) error {
+	result, err := tx.ExecContext(ctx, `
+		UPDATE paymentresults
+		SET status=$1, updated_at=$2, completed_at=$3, canceled_at=$4
+		WHERE id=$5 AND status=$6
+	`, payment.Status.Get(), payment.UpdatedAt, payment.CompletedAt, payment.CanceledAt, payment.ID.Get(), payments.Pending.Get())
+	if err != nil {
+		return fmt.Errorf("error while updating payment: %v", err)
+	}
+
+	return expectAffected(result, payments.ErrInvalidTransition)
+}
+
+// expectAffected returns errNone when the statement didn't touch any row
+func expectAffected(result sql.Result, errNone error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading affected rows: %v", err)
+	}
+	if affected == 0 {
+		return errNone
+	}
+
+	return nil
+}
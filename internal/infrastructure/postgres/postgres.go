@@ -21,6 +21,13 @@ func New() (*Database, error) {
 	}, nil
 }
 
+// NewWithDB wraps a connection that is already open, tests hand it a mocked one
+func NewWithDB(db *sqlx.DB) *Database {
+	return &Database{
+		db: db,
+	}
+}
+
 // Close a wrapper
 func (d *Database) Close() error {
 	return d.db.Close()
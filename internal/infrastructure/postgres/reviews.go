@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/reviews"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type ReviewRepository struct {
+	*Database
+}
+
+func NewReviewRepository(db *Database) *ReviewRepository {
+	return &ReviewRepository{
+		Database: db,
+	}
+}
+
+type dbReviewDTO struct {
+	ID      int64  `db:"id"`
+	UserID  int64  `db:"user_id"`
+	BookID  int64  `db:"book_id"`
+	Name    string `db:"name"`
+	Rating  int    `db:"rating"`
+	Comment string `db:"comment"`
+
+	CreatedAt *time.Time `db:"created_at"`
+	UpdatedAt *time.Time `db:"updated_at"`
+}
+
+func (d *dbReviewDTO) newReview() (*reviews.Review, error) {
+	review, err := reviews.NewReview(d.Rating, d.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	review.UserID = d.UserID
+	review.BookID = d.BookID
+	review.Name = d.Name
+	review.CreatedAt = d.CreatedAt
+	review.UpdatedAt = d.UpdatedAt
+
+	err = review.Create(d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+func newReviewDTO(review *reviews.Review) *dbReviewDTO {
+	return &dbReviewDTO{
+		ID:        review.ID.Get(),
+		UserID:    review.UserID,
+		BookID:    review.BookID,
+		Name:      review.Name,
+		Rating:    review.Rating.Get(),
+		Comment:   review.Comment.Get(),
+		CreatedAt: review.CreatedAt,
+		UpdatedAt: review.UpdatedAt,
+	}
+}
+
+const selectReviewQuery = `
+	SELECT id, user_id, book_id, COALESCE(name, '') AS name, rating, COALESCE(comment, '') AS comment, created_at, updated_at
+	FROM reviews
+`
+
+func (r *ReviewRepository) Create(ctx context.Context, review *reviews.Review) (*reviews.Review, error) {
+	// The reviewer's name is copied from the user, the unique index keeps it to one review per book
+	query := `
+		INSERT INTO reviews (user_id, book_id, name, rating, comment)
+		SELECT :user_id, :book_id, u.name, :rating, :comment
+		FROM users u
+		WHERE u.id = :user_id
+		ON CONFLICT (user_id, book_id)
+		DO NOTHING
+		RETURNING id, user_id, book_id, name, rating, comment, created_at, updated_at
+	`
+
+	stmt, err := r.db.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %v", err)
+	}
+
+	reviewDTO := newReviewDTO(review)
+	err = stmt.GetContext(ctx, reviewDTO, reviewDTO)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error while inserting review: book already reviewed")
+		}
+		return nil, fmt.Errorf("error while inserting review: %v", err)
+	}
+
+	return reviewDTO.newReview()
+}
+
+func (r *ReviewRepository) GetById(ctx context.Context, id int64) (*reviews.Review, error) {
+	reviewDTO := &dbReviewDTO{}
+	err := r.db.GetContext(ctx, reviewDTO, selectReviewQuery+"WHERE id=$1", id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting review: %v", err)
+	}
+
+	return reviewDTO.newReview()
+}
+
+func (r *ReviewRepository) GetAllByBookId(ctx context.Context, bookID int64, page int64) ([]*reviews.Review, error) {
+	limit := 20
+	offset := limit * (int(page) - 1)
+
+	query := selectReviewQuery + `
+		WHERE book_id=$1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+		OFFSET $3
+	`
+
+	var reviewsDTO []*dbReviewDTO
+	err := r.db.SelectContext(ctx, &reviewsDTO, query, bookID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reviews: %v", err)
+	}
+
+	allReviews := make([]*reviews.Review, 0, len(reviewsDTO))
+	for _, reviewDTO := range reviewsDTO {
+		review, err := reviewDTO.newReview()
+		if err != nil {
+			return nil, err
+		}
+		allReviews = append(allReviews, review)
+	}
+
+	return allReviews, nil
+}
+
+func (r *ReviewRepository) Update(ctx context.Context, review *reviews.Review) (*reviews.Review, error) {
+	stmt, err := r.db.PrepareNamedContext(ctx, `
+		UPDATE reviews
+		SET rating=:rating, comment=:comment, updated_at=:updated_at
+		WHERE id=:id
+		RETURNING id, user_id, book_id, name, rating, comment, created_at, updated_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %v", err)
+	}
+
+	reviewDTO := newReviewDTO(review)
+	err = stmt.GetContext(ctx, reviewDTO, reviewDTO)
+	if err != nil {
+		return nil, fmt.Errorf("error while updating review: %v", err)
+	}
+
+	return reviewDTO.newReview()
+}
+
+func (r *ReviewRepository) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM reviews WHERE id=$1", id)
+	if err != nil {
+		return fmt.Errorf("error deleting review: %v", err)
+	}
+
+	return nil
+}
+
+// HasPurchased reports whether the user has a paid order containing the book
+func (r *ReviewRepository) HasPurchased(ctx context.Context, userID int64, bookID int64) (bool, error) {
+	var purchased bool
+	err := r.db.GetContext(ctx, &purchased, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM orders o
+			JOIN orderbooks ob ON ob.order_id = o.id
+			WHERE o.user_id = $1 AND ob.book_id = $2 AND o.status = $3
+		)
+	`, userID, bookID, orders.Paid.Get())
+	if err != nil {
+		return false, fmt.Errorf("error checking purchase: %v", err)
+	}
+
+	return purchased, nil
+}
@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+const (
+	denylistPrefix      = "denylist:jti:"
+	sessionTokensPrefix = "session:tokens:"
+)
+
+// Denylist keeps denied token IDs as keys that expire along with the token, and the tokens of each session
+// in a sorted set scored by their expiry
+type Denylist struct {
+	cache *redis.Client
+}
+
+func NewDenylist(cache *redis.Client) *Denylist {
+	return &Denylist{
+		cache: cache,
+	}
+}
+
+func (d *Denylist) Track(ctx context.Context, sessionID string, tokenID string, expiresAt time.Time) error {
+	key := sessionTokensPrefix + sessionID
+
+	// The set lives as long as its longest lived token, NX and GT on EXPIRE need Redis 7
+	ttl := time.Until(expiresAt)
+	pipe := d.cache.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(expiresAt.Unix()), Member: tokenID})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	pipe.ExpireNX(ctx, key, ttl)
+	pipe.ExpireGT(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error tracking token: %v", err)
+	}
+
+	return nil
+}
+
+func (d *Denylist) DenySession(ctx context.Context, sessionID string) error {
+	key := sessionTokensPrefix + sessionID
+	now := time.Now()
+
+	tokens, err := d.cache.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min: strconv.FormatInt(now.Unix()+1, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("error listing session tokens: %v", err)
+	}
+
+	pipe := d.cache.TxPipeline()
+	for _, token := range tokens {
+		tokenID, ok := token.Member.(string)
+		if !ok {
+			continue
+		}
+		pipe.Set(ctx, denylistPrefix+tokenID, 1, time.Unix(int64(token.Score), 0).Sub(now))
+	}
+	pipe.Del(ctx, key)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error denying session tokens: %v", err)
+	}
+
+	return nil
+}
+
+func (d *Denylist) IsDenied(ctx context.Context, tokenID string) (bool, error) {
+	count, err := d.cache.Exists(ctx, denylistPrefix+tokenID).Result()
+	if err != nil {
+		return false, fmt.Errorf("error checking denylist: %v", err)
+	}
+
+	return count > 0, nil
+}
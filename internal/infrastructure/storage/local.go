@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"bookstore_api/internal/port"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid blob key")
+	ErrNotFound   = errors.New("blob not found")
+)
+
+const defaultLocalRoot = "storage"
+
+// LocalStorage keeps blobs as files under a root directory, the content type comes back from the file extension
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage() (*LocalStorage, error) {
+	root := os.Getenv("BLOB_STORAGE_DIR")
+	if root == "" {
+		root = defaultLocalRoot
+	}
+
+	err := os.MkdirAll(root, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{
+		root: root,
+	}, nil
+}
+
+// path resolves the key inside the root, keys that would climb out of it are refused
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean == "/" || clean != "/"+strings.TrimPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStorage) Put(_ context.Context, key string, data io.Reader, _ string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// Written next to the target and renamed over it, so a reader never gets half a file
+	file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	_, err = io.Copy(file, data)
+	if err != nil {
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(file.Name(), 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+func (s *LocalStorage) Get(_ context.Context, key string) (io.ReadCloser, *port.BlobInfo, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if stat.IsDir() {
+		file.Close()
+		return nil, nil, ErrNotFound
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return file, &port.BlobInfo{
+		ContentType: contentType,
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
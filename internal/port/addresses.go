@@ -0,0 +1,15 @@
+package port
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"context"
+)
+
+type AddressRepository interface {
+	Create(ctx context.Context, address *addresses.Address) (*addresses.Address, error)
+	GetById(ctx context.Context, id int64) (*addresses.Address, error)
+	GetAllByUserId(ctx context.Context, userID int64) ([]*addresses.Address, error)
+	Update(ctx context.Context, address *addresses.Address) (*addresses.Address, error)
+	Delete(ctx context.Context, id int64) error
+	SetDefault(ctx context.Context, address *addresses.Address) error
+}
@@ -0,0 +1,10 @@
+package port
+
+import (
+	"bookstore_api/internal/core/domain/authors"
+	"context"
+)
+
+type AuthorRepository interface {
+	GetBySlug(ctx context.Context, slug string) (*authors.Author, error)
+}
@@ -11,8 +11,15 @@ import (
 type BookRepository interface {
 	Create(ctx context.Context, book *books.Book) (*books.Book, error)
 	GetById(ctx context.Context, id int64) (*books.Book, error)
-	GetAll(ctx context.Context, page int64) ([]*books.Book, error)
+	GetBySlug(ctx context.Context, slug string) (*books.Book, error)
+	GetCurrentSlug(ctx context.Context, oldSlug string) (string, error)
+	GetAll(ctx context.Context, query *books.ListQuery) (*books.Page, error)
+	Search(ctx context.Context, query string, page int64) ([]*books.SearchResult, error)
+	Stream(ctx context.Context, fn func(book *books.Book) error) error
 	Update(ctx context.Context, book *books.Book) (*books.Book, error)
+	Upsert(ctx context.Context, book *books.Book) (*books.Book, bool, error)
+	// UpdateCover swaps the stored cover only while it still is the one given, it returns whether it did
+	UpdateCover(ctx context.Context, id int64, from string, to string) (bool, error)
 	Delete(ctx context.Context, id int64) error
 }
 
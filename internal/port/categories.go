@@ -0,0 +1,16 @@
+package port
+
+import (
+	"bookstore_api/internal/core/domain/categories"
+	"context"
+)
+
+type CategoryRepository interface {
+	Create(ctx context.Context, category *categories.Category) (*categories.Category, error)
+	GetById(ctx context.Context, id int64) (*categories.Category, error)
+	GetAll(ctx context.Context) ([]*categories.Category, error)
+	Update(ctx context.Context, category *categories.Category) (*categories.Category, error)
+	Delete(ctx context.Context, id int64) error
+	AttachBook(ctx context.Context, bookID int64, categoryID int64) error
+	DetachBook(ctx context.Context, bookID int64, categoryID int64) error
+}
@@ -0,0 +1,12 @@
+package port
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"context"
+)
+
+type OrderRepository interface {
+	Create(ctx context.Context, order *orders.Order) (*orders.Order, error)
+	GetById(ctx context.Context, id int64) (*orders.Order, error)
+	Cancel(ctx context.Context, order *orders.Order) (*orders.Order, error)
+}
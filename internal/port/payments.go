@@ -0,0 +1,30 @@
+package port
+
+import (
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/payments"
+	"context"
+)
+
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *payments.Payment) (*payments.Payment, error)
+	GetById(ctx context.Context, id int64) (*payments.Payment, error)
+	GetByOrderId(ctx context.Context, orderID int64) (*payments.Payment, error)
+	// Complete calls capture after the payment and its order are known to be payable,
+	// a failed capture leaves both untouched.
+	Complete(ctx context.Context, payment *payments.Payment, capture func(context.Context) error) (*payments.Payment, error)
+	// Cancel calls void after the payment is known to be pending, a failed void leaves the payment untouched.
+	Cancel(ctx context.Context, payment *payments.Payment, void func(context.Context) error) (*payments.Payment, error)
+	GetByProviderReference(ctx context.Context, reference string) (*payments.Payment, error)
+	// RecordEvent stores the event and, if changed, the new payment status atomically.
+	// It returns false when the event was already recorded before.
+	RecordEvent(ctx context.Context, event *payments.Event, payment *payments.Payment, changed bool) (bool, error)
+}
+
+// PaymentProvider talks to whoever actually moves the money for a payment method
+type PaymentProvider interface {
+	Method() orders.PaymentMethod
+	Initiate(ctx context.Context, payment *payments.Payment) (reference string, err error)
+	Capture(ctx context.Context, reference string) error
+	Void(ctx context.Context, reference string) error
+}
@@ -0,0 +1,15 @@
+package port
+
+import (
+	"bookstore_api/internal/core/domain/reviews"
+	"context"
+)
+
+type ReviewRepository interface {
+	Create(ctx context.Context, review *reviews.Review) (*reviews.Review, error)
+	GetById(ctx context.Context, id int64) (*reviews.Review, error)
+	GetAllByBookId(ctx context.Context, bookID int64, page int64) ([]*reviews.Review, error)
+	Update(ctx context.Context, review *reviews.Review) (*reviews.Review, error)
+	Delete(ctx context.Context, id int64) error
+	HasPurchased(ctx context.Context, userID int64, bookID int64) (bool, error)
+}
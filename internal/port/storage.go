@@ -0,0 +1,22 @@
+package port
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// BlobStorage keeps blobs by key, keys are slash separated paths like covers/1/original.jpg
+type BlobStorage interface {
+	Put(ctx context.Context, key string, data io.Reader, contentType string) error
+	// Get hands back the blob to be closed by the caller, it also implements io.Seeker when the storage can seek
+	Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,15 @@
+package port
+
+import (
+	"context"
+	"time"
+)
+
+// TokenDenylist keeps the IDs of access tokens that were revoked before they expire, each only for as long as it would live
+type TokenDenylist interface {
+	// Track remembers an access token was issued for the session, so revoking the session can deny it later
+	Track(ctx context.Context, sessionID string, tokenID string, expiresAt time.Time) error
+	// DenySession denies every access token tracked for the session that hasn't expired yet
+	DenySession(ctx context.Context, sessionID string) error
+	IsDenied(ctx context.Context, tokenID string) (bool, error)
+}
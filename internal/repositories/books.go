@@ -2,9 +2,13 @@ package repositories
 
 import (
 	"bookstore_api/models"
+	"bookstore_api/tools"
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"time"
 )
 
 type BookRepository struct {
@@ -20,7 +24,7 @@ func NewBookRepository(repository *Repository) *BookRepository {
 type IBookRepository interface {
 	Create(ctx context.Context, book *models.Book) (*models.Book, error)
 	GetById(ctx context.Context, id int64) (*models.Book, error)
-	GetAll(ctx context.Context, page int) ([]*models.Book, error)
+	GetAll(ctx context.Context, query *models.BookListQuery) (*models.BookPage, error)
 	Update(ctx context.Context, book *models.Book) (*models.Book, error)
 	Delete(ctx context.Context, id int64) error
 }
@@ -62,24 +66,115 @@ func (repo *BookRepository) GetById(ctx context.Context, id int64) (*models.Book
 	return book, nil
 }
 
-func (repo *BookRepository) GetAll(ctx context.Context, page int) ([]*models.Book, error) {
-	limit := 20
-	offset := limit * (page - 1)
+// bookSorts maps each sort option to the expression it orders by and the type its cursor value is cast to
+var bookSorts = map[string]struct {
+	expr string
+	cast string
+}{
+	"created_at": {expr: "created_at", cast: "timestamp"},
+	"price":      {expr: "price", cast: "numeric"},
+	"title":      {expr: "title", cast: "text"},
+	"rating":     {expr: "COALESCE((SELECT ROUND(AVG(rating), 2) FROM reviews WHERE book_id = books.id), 0)", cast: "numeric"},
+}
 
-	query := `
-		SELECT * 
-		FROM books 
-		LIMIT $1 
-		OFFSET $2
-	`
+// GetAll pages through the books with keyset pagination, so rows added or removed in between never shift a page
+func (repo *BookRepository) GetAll(ctx context.Context, query *models.BookListQuery) (*models.BookPage, error) {
+	sort, ok := bookSorts[query.Sort]
+	if !ok {
+		return nil, fmt.Errorf("error getting books: invalid sort %q", query.Sort)
+	}
+
+	var cursor *tools.Cursor
+	if query.Cursor != "" {
+		var err error
+		cursor, err = tools.DecodeCursor(query.Cursor, query.Sort, query.Descending)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Going back a page walks the listing the other way round, the rows get flipped afterwards
+	before := cursor != nil && cursor.Before
+	operator, direction := ">", "ASC"
+	if query.Descending != before {
+		operator, direction = "<", "DESC"
+	}
+
+	var args []any
+	sqlQuery := "SELECT * FROM books"
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		sqlQuery += fmt.Sprintf(" WHERE (%s, id) %s (CAST($1 AS %s), $2)", sort.expr, operator, sort.cast)
+	}
+	args = append(args, query.Limit+1)
+	sqlQuery += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT $%d", sort.expr, direction, direction, len(args))
 
 	var books []*models.Book
-	err := repo.Db.SelectContext(ctx, &books, query, limit, offset)
+	err := repo.Db.SelectContext(ctx, &books, sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error getting books: %v", err)
 	}
 
-	return books, nil
+	page := &models.BookPage{}
+	err = repo.Db.GetContext(ctx, &page.Total, "SELECT COUNT(*) FROM books")
+	if err != nil {
+		return nil, fmt.Errorf("error counting books: %v", err)
+	}
+
+	more := len(books) > query.Limit
+	if more {
+		books = books[:query.Limit]
+	}
+	if before {
+		slices.Reverse(books)
+	}
+	page.Books = books
+
+	if len(books) == 0 {
+		return page, nil
+	}
+
+	hasNext, hasPrev := more, cursor != nil
+	if before {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		page.NextCursor, err = repo.bookCursor(ctx, query, books[len(books)-1], false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		page.PrevCursor, err = repo.bookCursor(ctx, query, books[0], true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func (repo *BookRepository) bookCursor(ctx context.Context, query *models.BookListQuery, book *models.Book, before bool) (string, error) {
+	cursor := &tools.Cursor{Sort: query.Sort, Descending: query.Descending, ID: book.ID, Before: before}
+
+	switch query.Sort {
+	case "price":
+		cursor.Value = strconv.FormatFloat(book.Price, 'f', -1, 64)
+	case "title":
+		cursor.Value = book.Title
+	case "rating":
+		// The rating isn't part of the book model, it is looked up the same way it is sorted by
+		var rating float64
+		err := repo.Db.GetContext(ctx, &rating, "SELECT COALESCE(ROUND(AVG(rating), 2), 0) FROM reviews WHERE book_id = $1", book.ID)
+		if err != nil {
+			return "", fmt.Errorf("error getting book rating: %v", err)
+		}
+		cursor.Value = strconv.FormatFloat(rating, 'f', -1, 64)
+	default:
+		cursor.Value = book.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return cursor.Encode(), nil
 }
 
 func (repo *BookRepository) Update(ctx context.Context, book *models.Book) (*models.Book, error) {
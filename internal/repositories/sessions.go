@@ -3,10 +3,14 @@ package repositories
 import (
 	"bookstore_api/models"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 )
 
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
 type SessionRepository struct {
 	*Repository
 }
@@ -18,25 +22,47 @@ func NewSessionRepository(repository *Repository) *SessionRepository {
 }
 
 type ISessionRepository interface {
-	Create(ctx context.Context, session *models.Sessions) (*models.Sessions, error)
+	Create(ctx context.Context, session *models.Sessions, tokenHash string) (*models.Sessions, error)
 	Get(ctx context.Context, id string) (*models.Sessions, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, sessionID string, oldHash string, newHash string) (bool, error)
+	ListActive(ctx context.Context, email string) ([]*models.Sessions, error)
 	Revoke(ctx context.Context, id string) error
+	RevokeForUser(ctx context.Context, email string, id string) (bool, error)
+	RevokeAll(ctx context.Context, email string, exceptID string) ([]string, error)
 	Delete(ctx context.Context, id string) error
+	DeleteInactive(ctx context.Context, limit int) (int64, int64, error)
 }
 
-func (repo *SessionRepository) Create(ctx context.Context, session *models.Sessions) (*models.Sessions, error) {
+// Create stores the session along with the hash of its first refresh token
+func (repo *SessionRepository) Create(ctx context.Context, session *models.Sessions, tokenHash string) (*models.Sessions, error) {
+	tx, err := repo.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO Sessions (id, user_email, refresh_token, expires_at) 
-		VALUES (:id, :user_email, :refresh_token, :expires_at) 
-		RETURNING *
+		INSERT INTO Sessions (id, user_email, user_agent, ip_address, created_at, expires_at) 
+		VALUES (:id, :user_email, :user_agent, :ip_address, :created_at, :expires_at)
     `
 
-	_, err := repo.Db.NamedExecContext(ctx, query, session)
+	_, err = tx.NamedExecContext(ctx, query, session)
 	if err != nil {
 		log.Printf("Here is the sql statement error: %v", err)
 		return nil, err
 	}
 
+	_, err = tx.ExecContext(ctx, "INSERT INTO RefreshTokens (token_hash, session_id) VALUES ($1, $2)", tokenHash, session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error storing refresh token: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
 	return session, nil
 }
 
@@ -50,6 +76,57 @@ func (repo *SessionRepository) Get(ctx context.Context, id string) (*models.Sess
 	return session, nil
 }
 
+func (repo *SessionRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	err := repo.Db.GetContext(ctx, token, "SELECT * FROM refreshtokens WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("error getting refresh token: %s", err)
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken retires the old token for the new one, it returns false when the old token was already rotated,
+// which is also how the losing side of two concurrent refreshes finds out
+func (repo *SessionRepository) RotateRefreshToken(ctx context.Context, sessionID string, oldHash string, newHash string) (bool, error) {
+	tx, err := repo.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE refreshtokens SET rotated_at = NOW()
+		WHERE token_hash = $1 AND session_id = $2 AND rotated_at IS NULL
+	`, oldHash, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("error rotating refresh token: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error rotating refresh token: %v", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO refreshtokens (token_hash, session_id) VALUES ($1, $2)", newHash, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("error storing refresh token: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return true, nil
+}
+
 func (repo *SessionRepository) Revoke(ctx context.Context, id string) error {
 	_, err := repo.Db.NamedExecContext(ctx, "UPDATE sessions SET is_revoked=TRUE WHERE id = :id", map[string]interface{}{"id": id})
 	if err != nil {
@@ -59,6 +136,51 @@ func (repo *SessionRepository) Revoke(ctx context.Context, id string) error {
 	return nil
 }
 
+// ListActive returns the sessions of the user that are neither revoked nor expired, newest first
+func (repo *SessionRepository) ListActive(ctx context.Context, email string) ([]*models.Sessions, error) {
+	sessions := make([]*models.Sessions, 0)
+	err := repo.Db.SelectContext(ctx, &sessions, `
+		SELECT * FROM sessions
+		WHERE user_email = $1 AND NOT is_revoked AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`, email)
+	if err != nil {
+		return nil, fmt.Errorf("error listing sessions: %s", err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeForUser revokes the session only if it belongs to the user, it returns whether it did
+func (repo *SessionRepository) RevokeForUser(ctx context.Context, email string, id string) (bool, error) {
+	result, err := repo.Db.ExecContext(ctx, "UPDATE sessions SET is_revoked=TRUE WHERE id = $1 AND user_email = $2 AND NOT is_revoked", id, email)
+	if err != nil {
+		return false, fmt.Errorf("error revoking session: %s", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error revoking session: %s", err)
+	}
+
+	return rows > 0, nil
+}
+
+// RevokeAll revokes every session of the user but the one given, an empty ID spares none. It returns the IDs it revoked.
+func (repo *SessionRepository) RevokeAll(ctx context.Context, email string, exceptID string) ([]string, error) {
+	revoked := make([]string, 0)
+	err := repo.Db.SelectContext(ctx, &revoked, `
+		UPDATE sessions SET is_revoked=TRUE
+		WHERE user_email = $1 AND id <> $2 AND NOT is_revoked
+		RETURNING id
+	`, email, exceptID)
+	if err != nil {
+		return nil, fmt.Errorf("error revoking sessions: %s", err)
+	}
+
+	return revoked, nil
+}
+
 func (repo *SessionRepository) Delete(ctx context.Context, id string) error {
 	_, err := repo.Db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1", id)
 	if err != nil {
@@ -67,3 +189,38 @@ func (repo *SessionRepository) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+// DeleteInactive deletes up to limit sessions that are revoked or expired, along with their refresh tokens.
+// It returns how many of them were revoked and how many had only expired.
+func (repo *SessionRepository) DeleteInactive(ctx context.Context, limit int) (int64, int64, error) {
+	query := `
+		WITH inactive AS (
+			SELECT id FROM sessions
+			WHERE is_revoked OR expires_at <= NOW()
+			ORDER BY expires_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		DELETE FROM sessions
+		USING inactive
+		WHERE sessions.id = inactive.id
+		RETURNING sessions.is_revoked
+	`
+
+	var deleted []bool
+	err := repo.Db.SelectContext(ctx, &deleted, query, limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error deleting inactive sessions: %s", err)
+	}
+
+	var revoked, expired int64
+	for _, isRevoked := range deleted {
+		if isRevoked {
+			revoked++
+		} else {
+			expired++
+		}
+	}
+
+	return revoked, expired, nil
+}
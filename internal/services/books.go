@@ -6,7 +6,6 @@ import (
 	"bookstore_api/tools"
 	"context"
 	"errors"
-	"regexp"
 	"strconv"
 	"time"
 )
@@ -53,36 +52,31 @@ func (s *BookService) GetBookById(ctx context.Context, idStr string) (*models.Bo
 	return book, nil
 }
 
-func (s *BookService) GetAllBooks(ctx context.Context) ([]*models.Book, error) {
-	page, exist := ctx.Value("page").(string)
-	if !exist || page == "" {
-		page = "1"
+func (s *BookService) GetAllBooks(ctx context.Context, query *models.BookListQuery) (*models.BookPage, error) {
+	if query.Sort == "" {
+		query.Sort = "created_at"
 	}
 
-	// Define a regex pattern to allow only positive integers
-	re := regexp.MustCompile(`^[1-9]\d*$`)
-	if !re.MatchString(page) {
-		return nil, errors.New("page not valid")
+	switch {
+	case query.Limit <= 0:
+		query.Limit = 20
+	case query.Limit > s.MaxPageSize:
+		query.Limit = s.MaxPageSize
 	}
 
-	pageNum, err := strconv.Atoi(page)
-	if err != nil {
-		return nil, errors.New("error converting string to integer")
-	}
-
-	books, err := s.bookRepo.GetAll(ctx, pageNum)
+	page, err := s.bookRepo.GetAll(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, book := range books {
+	for _, book := range page.Books {
 		err = s.decryptBook(book)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return books, nil
+	return page, nil
 }
 
 func (s *BookService) UpdateBook(ctx context.Context, book *models.Book) (*models.Book, error) {
@@ -148,7 +142,7 @@ func (s *BookService) DeleteBook(ctx context.Context, idStr string) error {
 // A generic name, since we'll encrypt/decrypt whatever might be necessary in the future, not just the image's URL
 // Since the book might later be JOIN'ed with other data?
 func (s *BookService) encryptBook(book *models.Book) error {
-	encryptedURL, err := tools.Encrypt([]byte(book.CoverImage), s.AESKey)
+	encryptedURL, err := s.Cipher.Encrypt([]byte(book.CoverImage))
 	if err != nil {
 		return err
 	}
@@ -158,7 +152,7 @@ func (s *BookService) encryptBook(book *models.Book) error {
 }
 
 func (s *BookService) decryptBook(book *models.Book) error {
-	decryptedURL, err := tools.Decrypt(book.CoverImage, s.AESKey)
+	decryptedURL, err := s.Cipher.Decrypt(book.CoverImage)
 	if err != nil {
 		return err
 	}
@@ -1,27 +1,33 @@
 package services
 
 import (
-	"encoding/base64"
+	"bookstore_api/tools"
 	"errors"
 	"os"
+	"strconv"
 )
 
 type Service struct {
-	AESKey []byte
+	Cipher      tools.Cipher
+	MaxPageSize int
 }
 
 func NewService() (*Service, error) {
-	encodedKey := os.Getenv("AES_KEY")
-	if encodedKey == "" {
-		return nil, errors.New("AES_KEY environment variable not set")
+	cipher, err := tools.CipherFromEnv()
+	if err != nil {
+		return nil, err
 	}
 
-	key, err := base64.StdEncoding.DecodeString(encodedKey)
-	if err != nil {
-		return nil, errors.New("failed to decode base64 key")
+	maxPageSize := 100
+	if value := os.Getenv("BOOKS_MAX_PAGE_SIZE"); value != "" {
+		maxPageSize, err = strconv.Atoi(value)
+		if err != nil || maxPageSize <= 0 {
+			return nil, errors.New("BOOKS_MAX_PAGE_SIZE must be a positive number")
+		}
 	}
 
 	return &Service{
-		AESKey: key,
+		Cipher:      cipher,
+		MaxPageSize: maxPageSize,
 	}, nil
 }
@@ -1,25 +1,304 @@
 package services
 
 import (
+	"bookstore_api/internal/port"
 	"bookstore_api/internal/repositories"
 	"bookstore_api/models"
+	"bookstore_api/tools"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"time"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, the session is revoked")
+	ErrSessionRevoked      = errors.New("revoked session")
+	ErrSessionExpired      = errors.New("session is expired")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// sessionDuration is how long a session lasts from sign in, refreshing hands out new tokens but never extends it
+const sessionDuration = 24 * time.Hour
+
+// DefaultSweepBatchSize is how many sessions a sweep deletes per statement, so it never holds many rows locked at once
+const DefaultSweepBatchSize = 1000
+
+// maxUserAgentLength is as much of the user agent as a session keeps
+const maxUserAgentLength = 512
+
 type SessionService struct {
 	*Service
 	sessionRepo repositories.ISessionRepository
+	denylist    port.TokenDenylist
 }
 
-func NewSessionService(service *Service, sessionRepo repositories.ISessionRepository) *SessionService {
+// NewSessionService takes the denylist revoked sessions put their access tokens on, without one those run out on their own
+func NewSessionService(service *Service, sessionRepo repositories.ISessionRepository, denylist port.TokenDenylist) *SessionService {
 	return &SessionService{
 		Service:     service,
 		sessionRepo: sessionRepo,
+		denylist:    denylist,
+	}
+}
+
+// hashRefreshToken is what gets stored in place of the token, the token itself is only ever known to the client
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession opens a session for the principal and hands out its first refresh token, the user agent and IP address
+// are only kept so the user can recognize the session later
+func (s *SessionService) CreateSession(ctx context.Context, principal *tools.Principal, userAgent string, ipAddress string) (*models.Sessions, *tools.CustomClaims, string, error) {
+	sessionID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, nil, "", errors.New("error generating session")
+	}
+
+	sessionPrincipal := *principal
+	sessionPrincipal.SessionID = sessionID.String()
+
+	claims, refreshToken, err := tools.GenerateToken(&sessionPrincipal, tools.RefreshToken, sessionDuration)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	session := &models.Sessions{
+		ID:        sessionPrincipal.SessionID,
+		UserEmail: principal.Email,
+		UserAgent: truncateUserAgent(userAgent),
+		IPAddress: ipAddress,
+		IsRevoked: false,
+		CreatedAt: claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+
+	session, err = s.sessionRepo.Create(ctx, session, hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return session, claims, refreshToken, nil
+}
+
+// IssueAccessToken hands out an access token for the principal's session, tracked so revoking the session denies it
+func (s *SessionService) IssueAccessToken(ctx context.Context, principal *tools.Principal, duration time.Duration) (*tools.CustomClaims, string, error) {
+	claims, accessToken, err := tools.GenerateToken(principal, tools.AccessToken, duration)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if s.denylist != nil && principal.SessionID != "" {
+		err = s.denylist.Track(ctx, principal.SessionID, claims.RegisteredClaims.ID, claims.ExpiresAt.Time)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// A revocation that denied the session's tokens before this one was tracked would miss it,
+		// so the session is checked again now that any later revocation is bound to deny it
+		session, err := s.sessionRepo.Get(ctx, principal.SessionID)
+		if err != nil {
+			return nil, "", err
+		}
+		if session.IsRevoked {
+			err = s.denyAccessTokens(ctx, session.ID)
+			if err != nil {
+				return nil, "", err
+			}
+			return nil, "", ErrSessionRevoked
+		}
+	}
+
+	return claims, accessToken, nil
+}
+
+// denyAccessTokens puts the access tokens of revoked sessions on the denylist, so they stop working before they expire
+func (s *SessionService) denyAccessTokens(ctx context.Context, sessionIDs ...string) error {
+	if s.denylist == nil {
+		return nil
 	}
+
+	for _, sessionID := range sessionIDs {
+		err := s.denylist.DenySession(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (s *SessionService) CreateSession(ctx context.Context, session *models.Sessions) (*models.Sessions, error) {
-	return s.sessionRepo.Create(ctx, session)
+// GetSessionByRefreshToken finds the session of a refresh token, only its current token is accepted
+func (s *SessionService) GetSessionByRefreshToken(ctx context.Context, refreshToken string) (*models.Sessions, error) {
+	claims, err := tools.ValidateToken(refreshToken, tools.RefreshToken)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	stored, err := s.sessionRepo.GetRefreshToken(ctx, hashRefreshToken(refreshToken))
+	if err != nil || stored.RotatedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepo.Get(ctx, stored.SessionID)
+	if err != nil || claims.Subject != session.UserEmail {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return session, nil
+}
+
+// RotateRefreshToken trades a refresh token for a new one, each token can be used once. A token that was already traded
+// means someone else holds a copy of it, so the whole session is revoked along with every token it handed out.
+func (s *SessionService) RotateRefreshToken(ctx context.Context, refreshToken string) (*models.Sessions, *tools.CustomClaims, string, error) {
+	claims, err := tools.ValidateToken(refreshToken, tools.RefreshToken)
+	if err != nil {
+		return nil, nil, "", ErrInvalidRefreshToken
+	}
+
+	tokenHash := hashRefreshToken(refreshToken)
+	stored, err := s.sessionRepo.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, repositories.ErrRefreshTokenNotFound) {
+			return nil, nil, "", ErrInvalidRefreshToken
+		}
+		return nil, nil, "", err
+	}
+
+	if stored.RotatedAt != nil {
+		return nil, nil, "", s.revokeReusedSession(ctx, stored.SessionID)
+	}
+
+	session, err := s.sessionRepo.Get(ctx, stored.SessionID)
+	if err != nil {
+		return nil, nil, "", ErrInvalidRefreshToken
+	}
+
+	if claims.Subject != session.UserEmail {
+		return nil, nil, "", ErrInvalidRefreshToken
+	}
+
+	if session.IsRevoked {
+		return nil, nil, "", ErrSessionRevoked
+	}
+
+	if !session.ExpiresAt.After(time.Now()) {
+		err = s.sessionRepo.Revoke(ctx, session.ID)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return nil, nil, "", ErrSessionExpired
+	}
+
+	// The new token runs out with the session, not a full session duration after the refresh
+	principal := claims.Principal()
+	principal.SessionID = session.ID
+	newClaims, newToken, err := tools.GenerateToken(principal, tools.RefreshToken, time.Until(session.ExpiresAt))
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	rotated, err := s.sessionRepo.RotateRefreshToken(ctx, session.ID, tokenHash, hashRefreshToken(newToken))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if !rotated {
+		// Another request traded the same token in first
+		return nil, nil, "", s.revokeReusedSession(ctx, session.ID)
+	}
+
+	return session, newClaims, newToken, nil
+}
+
+func (s *SessionService) revokeReusedSession(ctx context.Context, sessionID string) error {
+	log.Printf("refresh token of session %s was used twice, revoking the session", sessionID)
+
+	err := s.sessionRepo.Revoke(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	err = s.denyAccessTokens(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// ListSessions returns the active sessions of the caller
+func (s *SessionService) ListSessions(ctx context.Context) ([]*models.SessionResponse, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionRepo.ListActive(ctx, principal.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, &models.SessionResponse{
+			ID:        session.ID,
+			UserAgent: session.UserAgent,
+			IPAddress: session.IPAddress,
+			Current:   session.ID == principal.SessionID,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+		})
+	}
+
+	return responses, nil
+}
+
+// RevokeUserSession revokes one of the caller's sessions, someone else's session is as good as not found
+func (s *SessionService) RevokeUserSession(ctx context.Context, sessionID string) error {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.sessionRepo.RevokeForUser(ctx, principal.Email, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	return s.denyAccessTokens(ctx, sessionID)
+}
+
+// RevokeOtherSessions signs the caller out everywhere but the session the request came from
+func (s *SessionService) RevokeOtherSessions(ctx context.Context) (int64, error) {
+	principal, err := tools.PrincipalFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked, err := s.sessionRepo.RevokeAll(ctx, principal.Email, principal.SessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(revoked)), s.denyAccessTokens(ctx, revoked...)
+}
+
+// RevokeAllSessions signs the user out everywhere, for when their credentials change
+func (s *SessionService) RevokeAllSessions(ctx context.Context, email string) error {
+	revoked, err := s.sessionRepo.RevokeAll(ctx, email, "")
+	if err != nil {
+		return err
+	}
+
+	return s.denyAccessTokens(ctx, revoked...)
 }
 
 func (s *SessionService) GetSession(ctx context.Context, sessionID string) (*models.Sessions, error) {
@@ -27,9 +306,80 @@ func (s *SessionService) GetSession(ctx context.Context, sessionID string) (*mod
 }
 
 func (s *SessionService) RevokeSession(ctx context.Context, sessionID string) error {
-	return s.sessionRepo.Revoke(ctx, sessionID)
+	err := s.sessionRepo.Revoke(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return s.denyAccessTokens(ctx, sessionID)
 }
 
 func (s *SessionService) DeleteSession(ctx context.Context, sessionID string) error {
-	return s.sessionRepo.Delete(ctx, sessionID)
+	err := s.sessionRepo.Delete(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return s.denyAccessTokens(ctx, sessionID)
+}
+
+// SweepReport counts the sessions a sweep deleted
+type SweepReport struct {
+	Revoked int64
+	Expired int64
+}
+
+// SweepSessions deletes every revoked and expired session in batches, it stops between batches once the context is done
+func (s *SessionService) SweepSessions(ctx context.Context, batchSize int) (*SweepReport, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultSweepBatchSize
+	}
+
+	report := &SweepReport{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		revoked, expired, err := s.sessionRepo.DeleteInactive(ctx, batchSize)
+		if err != nil {
+			return report, err
+		}
+		report.Revoked += revoked
+		report.Expired += expired
+
+		if revoked+expired < int64(batchSize) {
+			return report, nil
+		}
+	}
+}
+
+// RunSessionSweeper sweeps the sessions right away and then every interval, until the context is done
+func (s *SessionService) RunSessionSweeper(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.SweepSessions(ctx, batchSize)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("error sweeping sessions: %v", err)
+		case report.Revoked > 0 || report.Expired > 0:
+			log.Printf("swept %d revoked and %d expired sessions", report.Revoked, report.Expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func truncateUserAgent(userAgent string) string {
+	runes := []rune(userAgent)
+	if len(runes) <= maxUserAgentLength {
+		return userAgent
+	}
+	return string(runes[:maxUserAgentLength])
 }
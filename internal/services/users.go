@@ -14,13 +14,15 @@ import (
 
 type UserService struct {
 	*Service
-	userRepo repositories.IUserRepository
+	userRepo       repositories.IUserRepository
+	sessionService *SessionService
 }
 
-func NewUserService(service *Service, userRepo repositories.IUserRepository) *UserService {
+func NewUserService(service *Service, userRepo repositories.IUserRepository, sessionService *SessionService) *UserService {
 	return &UserService{
-		Service:  service,
-		userRepo: userRepo,
+		Service:        service,
+		userRepo:       userRepo,
+		sessionService: sessionService,
 	}
 }
 
@@ -73,13 +75,12 @@ func (s *UserService) LoginUser(ctx context.Context, user *models.UserLogin) (*m
 }
 
 func (s *UserService) UpdateUserData(ctx context.Context, userData *models.UserUpdateData) (*models.UserResponse, error) {
-	token := ctx.Value("token").(string)
-	claims, err := tools.ValidateToken(token)
+	principal, err := tools.PrincipalFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	email := claims.Subject
+	email := principal.Email
 
 	checkUser, err := s.userRepo.Get(ctx, email)
 	if err != nil {
@@ -102,8 +103,7 @@ func (s *UserService) UpdateUserData(ctx context.Context, userData *models.UserU
 }
 
 func (s *UserService) UpdateUserEmail(ctx context.Context, updatedEmail string) (*models.UserResponse, error) {
-	token := ctx.Value("token").(string)
-	claims, err := tools.ValidateToken(token)
+	principal, err := tools.PrincipalFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +114,7 @@ func (s *UserService) UpdateUserEmail(ctx context.Context, updatedEmail string)
 		return nil, err
 	}
 
-	email := claims.Subject
+	email := principal.Email
 	checkUser, err := s.userRepo.Get(ctx, email)
 	if err != nil {
 		return nil, err
@@ -129,14 +129,19 @@ func (s *UserService) UpdateUserEmail(ctx context.Context, updatedEmail string)
 		return nil, err
 	}
 
+	// Sessions belong to the old email, none of them survive the change
+	err = s.sessionService.RevokeAllSessions(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
 	userResponse := s.convertToResponse(updatedUser)
 
 	return userResponse, nil
 }
 
 func (s *UserService) UpdateUserPassword(ctx context.Context, updatedPassword string) (*models.UserResponse, error) {
-	token := ctx.Value("token").(string)
-	claims, err := tools.ValidateToken(token)
+	principal, err := tools.PrincipalFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -146,7 +151,7 @@ func (s *UserService) UpdateUserPassword(ctx context.Context, updatedPassword st
 		return nil, err
 	}
 
-	email := claims.Subject
+	email := principal.Email
 	checkUser, err := s.userRepo.Get(ctx, email)
 	if err != nil {
 		return nil, err
@@ -166,8 +171,11 @@ func (s *UserService) UpdateUserPassword(ctx context.Context, updatedPassword st
 		return nil, err
 	}
 
-	// Okay, maybe for this and email, I must invalidate sessions.
-	// Hey, you know, instead of injecting sessions service into user service, I should just inject it to user handler
+	// Whoever knew the old password could have signed in anywhere, so every session goes
+	err = s.sessionService.RevokeAllSessions(ctx, email)
+	if err != nil {
+		return nil, err
+	}
 
 	userResponse := s.convertToResponse(updatedUser)
 
@@ -266,12 +274,12 @@ func (s *UserService) checkPassword(password string, hashedPassword string) erro
 
 // Not worth it, would require me to do an extra query. Just hash the pass, and we're good. Only commenting, just in case.
 /*func (s *UserService) encryptUser(user *models.UserRegister) error {
-	encryptedName, err := tools.Encrypt([]byte(user.Name), s.AESKey)
+	encryptedName, err := s.Cipher.Encrypt([]byte(user.Name))
 	if err != nil {
 		return err
 	}
 
-	encryptedEmail, err := tools.Encrypt([]byte(user.Email), s.AESKey)
+	encryptedEmail, err := s.Cipher.Encrypt([]byte(user.Email))
 	if err != nil {
 		return err
 	}
@@ -283,12 +291,12 @@ func (s *UserService) checkPassword(password string, hashedPassword string) erro
 }
 
 func (s *UserService) decryptUser(user *models.UserResponse) error {
-	decryptedName, err := tools.Decrypt(user.Name, s.AESKey)
+	decryptedName, err := s.Cipher.Decrypt(user.Name)
 	if err != nil {
 		return err
 	}
 
-	decryptedEmail, err := tools.Decrypt(user.Email, s.AESKey)
+	decryptedEmail, err := s.Cipher.Decrypt(user.Email)
 	if err != nil {
 		return err
 	}
@@ -5,7 +5,7 @@ import "time"
 // Book represents the structure of the Books table in the database.
 type Book struct {
 	ID    int64  `json:"id" db:"id"`       // ID is the primary key of the book.
-	Title string `json:"title" db:"title"` // Title is the unique title of the book.
+	Title string `json:"title" db:"title"` // Title is the title of the book, unique among books without an ISBN.
 
 	Slug       string `json:"slug" db:"slug"`               // Slug is a unique identifier for the book based off Title.
 	CoverImage string `json:"cover_image" db:"cover_image"` // CoverImage holds the URL/path to the book's cover image.
@@ -14,9 +14,33 @@ type Book struct {
 	Price float64 `json:"price" db:"price"` // Price is the cost of the book, with 2 decimal places.
 	Stock int64   `json:"stock" db:"stock"` // Stock represents how many copies of the book are available.
 
+	ISBN        *string    `json:"isbn" db:"isbn"`                 // ISBN is the ISBN-13 of the edition, editions of a title tell apart by it. Nullable.
+	Publisher   *string    `json:"publisher" db:"publisher"`       // Publisher is who published the edition. Nullable.
+	PageCount   *int64     `json:"page_count" db:"page_count"`     // PageCount is how many pages the edition has. Nullable.
+	Language    *string    `json:"language" db:"language"`         // Language is the BCP 47 tag of the language the edition is in. Nullable.
+	PublishedOn *time.Time `json:"published_on" db:"published_on"` // PublishedOn is the publication date of the edition. Nullable.
+
+	SearchVector string `json:"-" db:"search_vector"` // SearchVector is generated by the database for full-text search, never written.
+
 	CreatedAt time.Time  `json:"created_at" db:"created_at"` // CreatedAt holds the timestamp when the book was created.
 	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"` // UpdatedAt holds the timestamp when the book was last updated. Nullable.
 }
 
+// BookListQuery asks for a page of books, Cursor comes from the previous page.
+type BookListQuery struct {
+	Sort       string // Sort is one of price, created_at, title or rating.
+	Descending bool   // Descending reverses the sort order.
+	Cursor     string // Cursor is the opaque position to continue from, empty for the first page.
+	Limit      int    // Limit is the page size, capped by the service.
+}
+
+// BookPage is a page of books, an empty cursor means there is nothing more in that direction.
+type BookPage struct {
+	Books      []*Book `json:"books"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
+	Total      int64   `json:"total"`
+}
+
 // json tag is needed for marshalling
 // db tag is to map the field to the database queries
@@ -3,15 +3,41 @@ package models
 import "time"
 
 type Sessions struct {
-	ID           string    `json:"id" db:"id"`
-	UserEmail    string    `json:"user_email" db:"user_email"`
-	RefreshToken string    `json:"refresh_token" db:"refresh_token"`
-	IsRevoked    bool      `json:"is_revoked" db:"is_revoked"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+	ID        string    `json:"id" db:"id"`
+	UserEmail string    `json:"user_email" db:"user_email"`
+	UserAgent string    `json:"user_agent" db:"user_agent"`
+	IPAddress string    `json:"ip_address" db:"ip_address"`
+	IsRevoked bool      `json:"is_revoked" db:"is_revoked"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// SessionResponse is a session as its user gets to see it, Current marks the one the request came from
+type SessionResponse struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IPAddress string    `json:"ip_address"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// RefreshToken is one token of a session, only its hash is ever stored
+type RefreshToken struct {
+	TokenHash string     `json:"-" db:"token_hash"`
+	SessionID string     `json:"session_id" db:"session_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at" db:"rotated_at"`
 }
 
 type RenewAccessTokenResponse struct {
 	AccessToken          string    `json:"access_token"`
 	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
 }
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bookstore_api/internal/infrastructure/http/controller"
+	"bookstore_api/internal/services"
+	"bookstore_api/tools"
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	_, adminToken, err := tools.GenerateToken(&tools.Principal{UserID: 1, Email: "admin@example.com", IsAdmin: true}, tools.AccessToken, time.Minute)
+	require.NoError(t, err)
+	_, userToken, err := tools.GenerateToken(&tools.Principal{UserID: 2, Email: "user@example.com"}, tools.AccessToken, time.Minute)
+	require.NoError(t, err)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := controller.Authenticator(newMemoryDenylist())(controller.RequireAdmin(next))
+
+	cases := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "Admin", header: "Bearer " + adminToken, status: http.StatusNoContent},
+		{name: "Not Admin", header: "Bearer " + userToken, status: http.StatusForbidden},
+		{name: "Missing Token", header: "", status: http.StatusUnauthorized},
+		{name: "Invalid Token", header: "Bearer invalid", status: http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/books/1", nil)
+			if c.header != "" {
+				req.Header.Set("Authorization", c.header)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, c.status, rec.Code)
+		})
+	}
+}
+
+func TestAuthenticatePrincipal(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	principal := &tools.Principal{UserID: 7, Email: "user@example.com", SessionID: "session"}
+	_, token, err := tools.GenerateToken(principal, tools.AccessToken, time.Minute)
+	require.NoError(t, err)
+
+	var got *tools.Principal
+	handler := controller.Authenticator(newMemoryDenylist())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err = tools.PrincipalFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NoError(t, err)
+	require.Equal(t, principal, got)
+
+	_, err = tools.PrincipalFromContext(req.Context())
+	require.ErrorIs(t, err, tools.ErrUnauthenticated)
+}
+
+func TestAuthenticatorDenylist(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	claims, token, err := tools.GenerateToken(&tools.Principal{UserID: 7, Email: "user@example.com", SessionID: "session"}, tools.AccessToken, time.Minute)
+	require.NoError(t, err)
+
+	denylist := newMemoryDenylist()
+	handler := controller.Authenticator(denylist)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, serve())
+
+	require.NoError(t, denylist.Track(context.Background(), "session", claims.RegisteredClaims.ID, claims.ExpiresAt.Time))
+	require.Equal(t, http.StatusNoContent, serve())
+
+	require.NoError(t, denylist.DenySession(context.Background(), "session"))
+	require.Equal(t, http.StatusUnauthorized, serve())
+}
+
+func TestAuthenticatorRefusesRefreshTokens(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	sessionService := services.NewSessionService(&services.Service{}, newMemorySessionRepository(), newMemoryDenylist())
+	_, _, refreshToken, err := sessionService.CreateSession(context.Background(), &tools.Principal{UserID: 7, Email: "user@example.com"}, "curl/8.0", "127.0.0.1")
+	require.NoError(t, err)
+
+	// Once rotated the token is worth nothing, not even as a bearer token
+	_, _, _, err = sessionService.RotateRefreshToken(context.Background(), refreshToken)
+	require.NoError(t, err)
+
+	handler := controller.Authenticator(newMemoryDenylist())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshToken)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/internal/infrastructure/http/controller"
+	"bookstore_api/internal/infrastructure/postgres"
+	"bookstore_api/tools"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// withBookRepositoryMock hands out a book repository over a mocked database, queries are matched as regular expressions
+func withBookRepositoryMock(t *testing.T, fn func(*postgres.BookRepository, sqlmock.Sqlmock)) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fn(postgres.NewBookRepository(postgres.NewWithDB(sqlx.NewDb(conn, "sqlmock"))), mock)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookFacets(t *testing.T) {
+	withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM books b") + "$").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+		// Every limit is bound, so bucket 1 is the lowest band and bucket 5 the open ended one
+		mock.ExpectQuery(regexp.QuoteMeta("width_bucket(b.price, ARRAY[CAST($1 AS numeric), CAST($2 AS numeric), CAST($3 AS numeric), CAST($4 AS numeric), CAST($5 AS numeric)])")).
+			WithArgs(0.0, 10.0, 25.0, 50.0, 100.0).
+			WillReturnRows(sqlmock.NewRows([]string{"band", "count"}).AddRow(1, 2).AddRow(2, 1).AddRow(5, 4))
+
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM books b WHERE b.stock > 0")).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT c.slug, c.name, COUNT(*) AS count")).
+			WillReturnRows(sqlmock.NewRows([]string{"slug", "name", "count"}).AddRow("fantasy", "Fantasy", 3))
+		mock.ExpectQuery(regexp.QuoteMeta("ORDER BY b.created_at ASC, b.id ASC LIMIT $1")).
+			WithArgs(21).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		page, err := r.GetAll(context.Background(), &books.ListQuery{Sort: books.SortCreatedAt, Limit: 20})
+		require.NoError(t, err)
+		require.Equal(t, int64(7), page.Total)
+		require.Equal(t, int64(5), page.Facets.InStock)
+		require.Len(t, page.Facets.Categories, 1)
+
+		counts := make([]int64, 0, len(page.Facets.PriceBands))
+		for _, band := range page.Facets.PriceBands {
+			counts = append(counts, band.Count)
+		}
+		require.Equal(t, []int64{2, 1, 0, 0, 4}, counts)
+		require.Equal(t, 0.0, page.Facets.PriceBands[0].Min)
+		require.Equal(t, 10.0, *page.Facets.PriceBands[0].Max)
+		require.Nil(t, page.Facets.PriceBands[4].Max)
+	})
+}
+
+func TestSearchBooks(t *testing.T) {
+	columns := []string{
+		"id", "title", "slug", "cover_image", "synopsis", "price", "stock",
+		"isbn", "publisher", "page_count", "language", "published_on", "created_at", "updated_at",
+		"rating", "num_reviews", "categories", "authors", "rank", "title_highlight", "synopsis_highlight",
+	}
+
+	t.Run("Escapes Before Highlighting", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			// The markup of the source is escaped inside ts_headline, the <mark> tags are the only ones left
+			escaped := func(expression string) string {
+				return regexp.QuoteMeta(`ts_headline('english', replace(replace(replace(replace(` + expression + `, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), q`)
+			}
+			mock.ExpectQuery(escaped("b.title")+`(.|\n)*`+escaped("COALESCE(b.synopsis, '')")).
+				WithArgs("naruto", 20, 20).
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(
+					1, "Naruto <b>Vol. 1</b>", "naruto-vol-1", "https://example.com/naruto.jpg", "A ninja & his <i>dream</i>", 9.99, 3,
+					nil, nil, nil, nil, nil, nil, nil,
+					4.5, 2, []byte(`["Manga"]`), []byte(`[]`), 0.6,
+					"<mark>Naruto</mark> &lt;b&gt;Vol. 1&lt;/b&gt;", "A ninja &amp; his &lt;i&gt;dream&lt;/i&gt;",
+				))
+
+			results, err := r.Search(context.Background(), "naruto", 2)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			require.Equal(t, int64(1), results[0].Book.ID.Get())
+			require.Equal(t, 0.6, results[0].Rank)
+			require.Equal(t, "<mark>Naruto</mark> &lt;b&gt;Vol. 1&lt;/b&gt;", results[0].TitleHighlight)
+			require.Equal(t, []string{"Manga"}, results[0].Book.Categories)
+		})
+	})
+
+	t.Run("Ranked Best First", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("WHERE b.search_vector @@ q")+`\s+`+regexp.QuoteMeta("ORDER BY rank DESC, b.id")).
+				WithArgs(`"one piece" or bleach`, 20, 0).
+				WillReturnRows(sqlmock.NewRows(columns))
+
+			results, err := r.Search(context.Background(), `"one piece" or bleach`, 1)
+			require.NoError(t, err)
+			require.Empty(t, results)
+		})
+	})
+}
+
+func TestBookSlugs(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	t.Setenv("AES_KEY", base64.StdEncoding.EncodeToString(key))
+	cover, err := tools.Encrypt([]byte("https://example.com/naruto.jpg"), key)
+	require.NoError(t, err)
+
+	bookRow := func(id int64, title, slug string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{
+			"id", "title", "slug", "cover_image", "synopsis", "price", "stock",
+			"isbn", "publisher", "page_count", "language", "published_on", "created_at", "updated_at",
+		}).AddRow(id, title, slug, "", "", 9.99, 3, nil, nil, nil, nil, nil, nil, nil)
+	}
+	uniqueSlug := regexp.QuoteMeta("SELECT slug FROM books WHERE (slug = $1 OR slug LIKE $2) AND id <> $3")
+	currentSlug := regexp.QuoteMeta("FROM bookslughistory h")
+
+	// getBySlug serves a book page through the handler, the way the router does
+	getBySlug := func(t *testing.T, repository *postgres.BookRepository, slug string) *httptest.ResponseRecorder {
+		bookService, err := service.NewBookService(repository)
+		require.NoError(t, err)
+
+		mux := chi.NewRouter()
+		mux.Get("/books/by-slug/{slug}", controller.NewBookHandler(bookService).GetBookBySlug)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/books/by-slug/"+slug, nil))
+		return res
+	}
+
+	t.Run("By Slug", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("WHERE b.slug=$1")).
+				WithArgs("naruto").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "cover_image", "price", "stock", "rating", "num_reviews"}).
+					AddRow(1, "Naruto", "naruto", cover, 9.99, 3, 0, 0))
+
+			res := getBySlug(t, r, "naruto")
+			require.Equal(t, http.StatusOK, res.Code)
+			require.Contains(t, res.Body.String(), `"slug":"naruto"`)
+		})
+	})
+
+	t.Run("Old Slug Moves Permanently", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("WHERE b.slug=$1")).
+				WithArgs("naruto").
+				WillReturnError(sql.ErrNoRows)
+			mock.ExpectQuery(currentSlug).
+				WithArgs("naruto").
+				WillReturnRows(sqlmock.NewRows([]string{"slug"}).AddRow("naruto-shippuden"))
+
+			res := getBySlug(t, r, "naruto")
+			require.Equal(t, http.StatusMovedPermanently, res.Code)
+			require.Equal(t, "/books/by-slug/naruto-shippuden", res.Header().Get("Location"))
+		})
+	})
+
+	t.Run("Unknown Slug", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("WHERE b.slug=$1")).WillReturnError(sql.ErrNoRows)
+			mock.ExpectQuery(currentSlug).WillReturnError(sql.ErrNoRows)
+
+			res := getBySlug(t, r, "bleach")
+			require.Equal(t, http.StatusNotFound, res.Code)
+		})
+	})
+
+	t.Run("Database Down", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			// An outage is no reason to look for an old slug, nor for crawlers to drop the page
+			mock.ExpectQuery(regexp.QuoteMeta("WHERE b.slug=$1")).WillReturnError(sql.ErrConnDone)
+
+			res := getBySlug(t, r, "naruto")
+			require.Equal(t, http.StatusInternalServerError, res.Code)
+		})
+	})
+
+	t.Run("History Down", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("WHERE b.slug=$1")).WillReturnError(sql.ErrNoRows)
+			mock.ExpectQuery(currentSlug).WillReturnError(sql.ErrConnDone)
+
+			res := getBySlug(t, r, "naruto")
+			require.Equal(t, http.StatusInternalServerError, res.Code)
+		})
+	})
+
+	t.Run("Rename Records History", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			book, err := books.NewBook("Naruto", "", "", 9.99, 3)
+			require.NoError(t, err)
+			require.NoError(t, book.Create(1))
+			book.UpdateTitle("Naruto Shippuden")
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT slug FROM books WHERE id=$1 FOR UPDATE")).
+				WithArgs(int64(1)).
+				WillReturnRows(sqlmock.NewRows([]string{"slug"}).AddRow("naruto"))
+			mock.ExpectPrepare("WITH title_conflict")
+			mock.ExpectQuery(uniqueSlug).
+				WithArgs("naruto-shippuden", "naruto-shippuden-%", int64(1)).
+				WillReturnRows(sqlmock.NewRows([]string{"slug"}))
+			mock.ExpectQuery("WITH title_conflict").
+				WillReturnRows(bookRow(1, "Naruto Shippuden", "naruto-shippuden"))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO bookslughistory (slug, book_id)")).
+				WithArgs("naruto", int64(1)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM bookslughistory WHERE slug=$1")).
+				WithArgs("naruto-shippuden").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM bookauthor WHERE book_id=$1")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+
+			updated, err := r.Update(context.Background(), book)
+			require.NoError(t, err)
+			require.Equal(t, "naruto-shippuden", updated.Slug.Get())
+		})
+	})
+
+	t.Run("Create Takes Over An Old Slug", func(t *testing.T) {
+		withBookRepositoryMock(t, func(r *postgres.BookRepository, mock sqlmock.Sqlmock) {
+			book, err := books.NewBook("Naruto", "", "", 9.99, 3)
+			require.NoError(t, err)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(uniqueSlug).
+				WithArgs("naruto", "naruto-%", int64(0)).
+				WillReturnRows(sqlmock.NewRows([]string{"slug"}))
+			mock.ExpectPrepare("INSERT INTO books")
+			mock.ExpectQuery("INSERT INTO books").
+				WillReturnRows(bookRow(2, "Naruto", "naruto"))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM bookslughistory WHERE slug=$1")).
+				WithArgs("naruto").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM bookauthor WHERE book_id=$1")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+
+			created, err := r.Create(context.Background(), book)
+			require.NoError(t, err)
+			require.Equal(t, "naruto", created.Slug.Get())
+		})
+	})
+}
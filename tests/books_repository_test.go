@@ -253,21 +253,25 @@ func TestGetAll(t *testing.T) {
 				ctx := context.Background()
 
 				// Mock the query for getting all books
-				query := `SELECT * FROM books LIMIT $1 OFFSET $2`
+				query := `SELECT * FROM books ORDER BY created_at ASC, id ASC LIMIT $1`
 
-				rows := sqlmock.NewRows([]string{"id", "title", "slug", "cover_image", "synopsis", "price", "stock"})
+				rows := sqlmock.NewRows([]string{"id", "title", "slug", "cover_image", "synopsis", "price", "stock", "created_at"})
 
 				for _, book := range books {
-					rows.AddRow(book.ID, book.Title, book.Slug, book.CoverImage, book.Synopsis, book.Price, book.Stock)
+					rows.AddRow(book.ID, book.Title, book.Slug, book.CoverImage, book.Synopsis, book.Price, book.Stock, book.CreatedAt)
 				}
 
-				mock.ExpectQuery(query).WillReturnRows(rows)
+				mock.ExpectQuery(query).WithArgs(3).WillReturnRows(rows)
+				mock.ExpectQuery(`SELECT COUNT(*) FROM books`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
 
 				// Call the repository method
-				retrievedBooks, err := r.GetAll(ctx, 1)
+				page, err := r.GetAll(ctx, &models.BookListQuery{Sort: "created_at", Limit: 2})
 				require.NoError(t, err)
-				require.NotNil(t, retrievedBooks)
-				require.Len(t, retrievedBooks, 2)
+				require.NotNil(t, page)
+				require.Len(t, page.Books, 2)
+				require.EqualValues(t, 2, page.Total)
+				require.Empty(t, page.NextCursor)
+				require.Empty(t, page.PrevCursor)
 
 				// Ensure all expectations are met
 				if err := mock.ExpectationsWereMet(); err != nil {
@@ -275,20 +279,61 @@ func TestGetAll(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "Next Page",
+			test: func(t *testing.T, r *repositories.BookRepository, mock sqlmock.Sqlmock) {
+				ctx := context.Background()
+
+				columns := []string{"id", "title", "slug", "cover_image", "synopsis", "price", "stock"}
+
+				// One more row than asked for means there is a next page
+				rows := sqlmock.NewRows(columns)
+				for _, book := range books {
+					rows.AddRow(book.ID, book.Title, book.Slug, book.CoverImage, book.Synopsis, book.Price, book.Stock)
+				}
+				mock.ExpectQuery(`SELECT * FROM books ORDER BY price ASC, id ASC LIMIT $1`).WithArgs(2).WillReturnRows(rows)
+				mock.ExpectQuery(`SELECT COUNT(*) FROM books`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+				page, err := r.GetAll(ctx, &models.BookListQuery{Sort: "price", Limit: 1})
+				require.NoError(t, err)
+				require.Len(t, page.Books, 1)
+				require.NotEmpty(t, page.NextCursor)
+				require.Empty(t, page.PrevCursor)
+
+				// The cursor continues right after the last book of the previous page
+				next := books[1]
+				rows = sqlmock.NewRows(columns).AddRow(next.ID, next.Title, next.Slug, next.CoverImage, next.Synopsis, next.Price, next.Stock)
+				mock.ExpectQuery(`SELECT * FROM books WHERE (price, id) > (CAST($1 AS numeric), $2) ORDER BY price ASC, id ASC LIMIT $3`).
+					WithArgs("10.99", int64(1), 2).
+					WillReturnRows(rows)
+				mock.ExpectQuery(`SELECT COUNT(*) FROM books`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+				page, err = r.GetAll(ctx, &models.BookListQuery{Sort: "price", Limit: 1, Cursor: page.NextCursor})
+				require.NoError(t, err)
+				require.Len(t, page.Books, 1)
+				require.Equal(t, next.ID, page.Books[0].ID)
+				require.Empty(t, page.NextCursor)
+				require.NotEmpty(t, page.PrevCursor)
+
+				if err := mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("there were unfulfilled expectations: %s", err)
+				}
+			},
+		},
 		{
 			name: "Query Error",
 			test: func(t *testing.T, r *repositories.BookRepository, mock sqlmock.Sqlmock) {
 				ctx := context.Background()
 
 				// Mock a query error
-				query := `SELECT * FROM books LIMIT $1 OFFSET $2`
+				query := `SELECT * FROM books ORDER BY created_at ASC, id ASC LIMIT $1`
 
 				mock.ExpectQuery(query).WillReturnError(fmt.Errorf("query error"))
 
 				// Call the repository method
-				retrievedBooks, err := r.GetAll(ctx, 1)
+				page, err := r.GetAll(ctx, &models.BookListQuery{Sort: "created_at", Limit: 20})
 				require.Error(t, err)
-				require.Nil(t, retrievedBooks)
+				require.Nil(t, page)
 
 				// Ensure all expectations are met
 				if err := mock.ExpectationsWereMet(); err != nil {
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewFilter(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+	date := func(v string) *time.Time {
+		d, err := time.Parse(time.DateOnly, v)
+		require.NoError(t, err)
+		return &d
+	}
+
+	cases := []struct {
+		name          string
+		minPrice      *float64
+		maxPrice      *float64
+		createdAfter  *time.Time
+		createdBefore *time.Time
+		err           error
+	}{
+		{name: "Empty"},
+		{name: "Price Range", minPrice: price(10), maxPrice: price(25)},
+		{name: "Single Price", minPrice: price(10), maxPrice: price(10)},
+		{name: "Inverted Price Range", minPrice: price(25), maxPrice: price(10), err: books.ErrPriceRange},
+		{name: "Negative Price", minPrice: price(-1), err: books.ErrNegativePrice},
+		{name: "Date Range", createdAfter: date("2024-01-01"), createdBefore: date("2024-02-01")},
+		{name: "Inverted Date Range", createdAfter: date("2024-02-01"), createdBefore: date("2024-01-01"), err: books.ErrDateRange},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			filter, err := books.NewFilter(c.minPrice, c.maxPrice, false, c.createdAfter, c.createdBefore, "")
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			if c.minPrice != nil {
+				require.Equal(t, *c.minPrice, filter.MinPrice.Get())
+			}
+		})
+	}
+}
+
+func TestNewISBN(t *testing.T) {
+	cases := []struct {
+		name string
+		isbn string
+		want string
+		err  error
+	}{
+		{name: "ISBN-13", isbn: "978-0-306-40615-7", want: "9780306406157"},
+		{name: "ISBN-10", isbn: "0 306 40615 2", want: "9780306406157"},
+		{name: "ISBN-10 With X", isbn: "0-8044-2957-X", want: "9780804429573"},
+		{name: "Empty", isbn: "", want: ""},
+		{name: "Bad Checksum", isbn: "9780306406158", err: books.ErrInvalidISBN},
+		{name: "Bad ISBN-10", isbn: "1569319001", err: books.ErrInvalidISBN},
+		{name: "Letters", isbn: "97814215825AB", err: books.ErrInvalidISBN},
+		{name: "Wrong Length", isbn: "12345", err: books.ErrInvalidISBN},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			isbn, err := books.NewISBN(c.isbn)
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.want, isbn.Get())
+		})
+	}
+}
+
+func TestNewDetails(t *testing.T) {
+	published := time.Date(2003, 3, 4, 15, 30, 0, 0, time.FixedZone("JST", 9*60*60))
+
+	details, err := books.NewDetails("", " Viz Media ", 192, "pt-br", &published)
+	require.NoError(t, err)
+	require.Equal(t, "Viz Media", details.Publisher.Get())
+	require.Equal(t, "pt-BR", details.Language.Get())
+	require.Equal(t, time.Date(2003, 3, 4, 0, 0, 0, 0, time.UTC), *details.PublishedOn)
+
+	_, err = books.NewDetails("", "", -1, "", nil)
+	require.ErrorIs(t, err, books.ErrPageCount)
+
+	_, err = books.NewDetails("", "", 0, "not a language", nil)
+	require.ErrorIs(t, err, books.ErrLanguage)
+}
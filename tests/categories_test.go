@@ -0,0 +1,34 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/categories"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestNewCategory(t *testing.T) {
+	cases := []struct {
+		name     string
+		category string
+		slug     string
+		err      error
+	}{
+		{name: "Success", category: "Science Fiction", slug: "science-fiction"},
+		{name: "Trimmed", category: "  Self Help  ", slug: "self-help"},
+		{name: "Empty Name", category: "   ", err: categories.ErrInvalidName},
+		{name: "Name Too Long", category: strings.Repeat("a", 101), err: categories.ErrInvalidName},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			category, err := categories.NewCategory(c.category)
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.slug, category.Slug.Get())
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/internal/infrastructure/storage"
+	"bookstore_api/internal/port"
+	"bookstore_api/tools"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// coverBookRepository keeps a single book, anything but reading and updating it panics
+type coverBookRepository struct {
+	port.BookRepository
+	book *books.Book
+}
+
+func (r *coverBookRepository) GetById(_ context.Context, id int64) (*books.Book, error) {
+	if id != r.book.ID.Get() {
+		return nil, service.ErrBookNotFound
+	}
+	book := *r.book
+	return &book, nil
+}
+
+func (r *coverBookRepository) Update(_ context.Context, book *books.Book) (*books.Book, error) {
+	updated := *book
+	r.book = &updated
+	return book, nil
+}
+
+func TestUploadCover(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	t.Setenv("AES_KEY", base64.StdEncoding.EncodeToString(key))
+	t.Setenv("BLOB_STORAGE_DIR", t.TempDir())
+
+	cover, err := tools.Encrypt([]byte("https://example.com/dead-link.jpg"), key)
+	require.NoError(t, err)
+	book, err := books.NewBook("Naruto", cover, "", 9.99, 1)
+	require.NoError(t, err)
+	require.NoError(t, book.Create(1))
+
+	bookService, err := service.NewBookService(&coverBookRepository{book: book})
+	require.NoError(t, err)
+	blobStorage, err := storage.NewLocalStorage()
+	require.NoError(t, err)
+	coverService := service.NewCoverService(bookService, blobStorage)
+
+	newPNG := func(width, height int) []byte {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(i)
+		}
+		img.Set(0, 0, color.Transparent)
+		out := &bytes.Buffer{}
+		require.NoError(t, png.Encode(out, img))
+		return out.Bytes()
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		updated, urls, err := coverService.UploadCover(context.Background(), 1, bytes.NewReader(newPNG(400, 600)))
+		require.NoError(t, err)
+		require.Equal(t, urls.Original, updated.CoverImage.Get())
+		require.Regexp(t, `^/covers/1/[0-9a-f]{16}/original\.png$`, urls.Original)
+
+		parts := strings.Split(urls.Thumbnail, "/")
+		blob, info, err := coverService.OpenCover(context.Background(), 1, parts[3], parts[4])
+		require.NoError(t, err)
+		defer blob.Close()
+		require.Equal(t, "image/jpeg", info.ContentType)
+
+		thumbnail, err := jpeg.Decode(blob)
+		require.NoError(t, err)
+		require.Equal(t, 150, thumbnail.Bounds().Dx())
+		require.Equal(t, 225, thumbnail.Bounds().Dy())
+	})
+
+	t.Run("Replaces Previous Upload", func(t *testing.T) {
+		_, first, err := coverService.UploadCover(context.Background(), 1, bytes.NewReader(newPNG(300, 300)))
+		require.NoError(t, err)
+		_, second, err := coverService.UploadCover(context.Background(), 1, bytes.NewReader(newPNG(300, 400)))
+		require.NoError(t, err)
+		require.NotEqual(t, first.Original, second.Original)
+
+		parts := strings.Split(first.Medium, "/")
+		_, _, err = coverService.OpenCover(context.Background(), 1, parts[3], parts[4])
+		require.ErrorIs(t, err, service.ErrCoverNotFound)
+	})
+
+	t.Run("Too Small", func(t *testing.T) {
+		_, _, err := coverService.UploadCover(context.Background(), 1, bytes.NewReader(newPNG(100, 150)))
+		require.ErrorIs(t, err, service.ErrCoverDimensions)
+	})
+
+	t.Run("Not An Image", func(t *testing.T) {
+		_, _, err := coverService.UploadCover(context.Background(), 1, strings.NewReader("<svg></svg>"))
+		require.ErrorIs(t, err, service.ErrCoverType)
+	})
+
+	t.Run("Unknown Book", func(t *testing.T) {
+		_, _, err := coverService.UploadCover(context.Background(), 2, bytes.NewReader(newPNG(300, 300)))
+		require.ErrorIs(t, err, service.ErrBookNotFound)
+	})
+
+	t.Run("Path Traversal", func(t *testing.T) {
+		_, _, err := coverService.OpenCover(context.Background(), 1, "..", "original.png")
+		require.ErrorIs(t, err, service.ErrCoverNotFound)
+	})
+}
+
+func TestResizeToWidth(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 600))
+	flat := tools.Flatten(src)
+
+	// Transparent pixels end up white, not black
+	resized := tools.ResizeToWidth(flat, 150)
+	require.Equal(t, image.Rect(0, 0, 150, 225), resized.Bounds())
+	require.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, resized.RGBAAt(75, 100))
+
+	// A cover narrower than the rendition is used as it is, without another copy
+	require.Same(t, flat, tools.ResizeToWidth(flat, 600))
+
+	// A sub-image is scaled from its own pixels, not from the top left corner of its parent
+	for y := 0; y < 600; y++ {
+		for x := 200; x < 400; x++ {
+			flat.Set(x, y, color.Black)
+		}
+	}
+	right := tools.ResizeToWidth(flat.SubImage(image.Rect(200, 0, 400, 600)).(*image.RGBA), 100)
+	require.Equal(t, color.RGBA{A: 255}, right.RGBAAt(50, 150))
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/internal/port"
+	"bookstore_api/tools"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// streamBookRepository only knows Stream, anything else the export calls panics
+type streamBookRepository struct {
+	port.BookRepository
+	books []*books.Book
+}
+
+func (r *streamBookRepository) Stream(_ context.Context, fn func(book *books.Book) error) error {
+	for _, book := range r.books {
+		if err := fn(book); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportBooks(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	t.Setenv("AES_KEY", base64.StdEncoding.EncodeToString(key))
+
+	newBooks := func() []*books.Book {
+		cover, err := tools.Encrypt([]byte("https://example.com/naruto.jpg"), key)
+		require.NoError(t, err)
+
+		naruto, err := books.NewBook("Naruto, Vol. 1", cover, "A ninja \"story\"", 9.99, 3)
+		require.NoError(t, err)
+		require.NoError(t, naruto.Create(1))
+		naruto.Categories = []string{"Manga"}
+		naruto.Details, err = books.NewDetails("978-0-306-40615-7", "Viz Media", 192, "de", nil)
+		require.NoError(t, err)
+		require.NoError(t, naruto.SetAuthors([]string{"Masashi Kishimoto"}))
+
+		// Covers are stored encrypted even when there isn't one
+		noCover, err := tools.Encrypt(nil, key)
+		require.NoError(t, err)
+
+		bleach, err := books.NewBook("Bleach", noCover, "", 4.5, 0)
+		require.NoError(t, err)
+		require.NoError(t, bleach.Create(2))
+
+		return []*books.Book{naruto, bleach}
+	}
+
+	export := func(format service.ExportFormat) string {
+		bookService, err := service.NewBookService(&streamBookRepository{books: newBooks()})
+		require.NoError(t, err)
+
+		out := &bytes.Buffer{}
+		require.NoError(t, bookService.ExportBooks(context.Background(), out, format))
+		return out.String()
+	}
+
+	t.Run("CSV Imports Back", func(t *testing.T) {
+		feed := export(service.ExportCSV)
+		require.Contains(t, feed, "https://example.com/naruto.jpg")
+
+		bookService, err := service.NewBookService(&upsertBookRepository{titles: map[string]int64{}})
+		require.NoError(t, err)
+
+		report, err := bookService.ImportBooks(context.Background(), strings.NewReader(feed), service.ImportCSV)
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Created)
+		require.Equal(t, "Naruto, Vol. 1", report.Lines[0].Title)
+	})
+
+	t.Run("JSONL", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(export(service.ExportJSONL)), "\n")
+		require.Len(t, lines, 2)
+		require.Contains(t, lines[0], `"cover_image":"https://example.com/naruto.jpg"`)
+		require.Contains(t, lines[0], `"authors":["Masashi Kishimoto"]`)
+		require.Contains(t, lines[1], `"categories":[]`)
+	})
+
+	t.Run("ONIX", func(t *testing.T) {
+		feed := &struct {
+			Products []struct {
+				Title        string `xml:"DescriptiveDetail>TitleDetail>TitleElement>TitleText"`
+				Cover        string `xml:"CollateralDetail>SupportingResource>ResourceVersion>ResourceLink"`
+				Availability string `xml:"ProductSupply>SupplyDetail>ProductAvailability"`
+				Price        string `xml:"ProductSupply>SupplyDetail>Price>PriceAmount"`
+				Identifiers  []struct {
+					Type  string `xml:"ProductIDType"`
+					Value string `xml:"IDValue"`
+				} `xml:"ProductIdentifier"`
+				Author   string `xml:"DescriptiveDetail>Contributor>PersonName"`
+				Language string `xml:"DescriptiveDetail>Language>LanguageCode"`
+			} `xml:"Product"`
+		}{}
+		require.NoError(t, xml.Unmarshal([]byte(export(service.ExportONIX)), feed))
+
+		require.Len(t, feed.Products, 2)
+		require.Equal(t, "Naruto, Vol. 1", feed.Products[0].Title)
+		require.Equal(t, "https://example.com/naruto.jpg", feed.Products[0].Cover)
+		require.Equal(t, "21", feed.Products[0].Availability)
+		require.Equal(t, "31", feed.Products[1].Availability)
+		require.Equal(t, "4.50", feed.Products[1].Price)
+		require.Len(t, feed.Products[0].Identifiers, 2)
+		require.Equal(t, "9780306406157", feed.Products[0].Identifiers[1].Value)
+		require.Equal(t, "Masashi Kishimoto", feed.Products[0].Author)
+		require.Equal(t, "ger", feed.Products[0].Language)
+		require.Len(t, feed.Products[1].Identifiers, 1)
+	})
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/books"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/internal/infrastructure/http/controller"
+	"bookstore_api/internal/port"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// upsertBookRepository only knows Upsert, anything else the import calls panics
+type upsertBookRepository struct {
+	port.BookRepository
+	titles map[string]int64
+}
+
+func (r *upsertBookRepository) Upsert(_ context.Context, book *books.Book) (*books.Book, bool, error) {
+	id, ok := r.titles[book.Title.Get()]
+	if !ok {
+		id = int64(len(r.titles) + 1)
+		r.titles[book.Title.Get()] = id
+	}
+	return book, !ok, book.Create(id)
+}
+
+func TestImportBooks(t *testing.T) {
+	t.Setenv("AES_KEY", base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+
+	cases := []struct {
+		name   string
+		format service.ImportFormat
+		feed   string
+		want   []service.ImportStatus
+		lines  []int
+	}{
+		{
+			name:   "CSV",
+			format: service.ImportCSV,
+			feed: "title,price,stock,cover_image,synopsis\n" +
+				"Naruto,9.99,10,https://example.com/naruto.jpg,Ninja\n" +
+				"Bleach,abc,1,,\n" +
+				"Naruto,12.50,,,Ninja again\n" +
+				"One Piece,-1,1,,\n" +
+				"Too,Few\n",
+			want:  []service.ImportStatus{service.ImportCreated, service.ImportRejected, service.ImportUpdated, service.ImportRejected, service.ImportRejected},
+			lines: []int{2, 3, 4, 5, 6},
+		},
+		{
+			name:   "Malformed CSV",
+			format: service.ImportCSV,
+			feed: "title,price,stock,cover_image,synopsis\n" +
+				"Bad \"quote,1,1,,\n" +
+				"Naruto,9.99,10,,\n",
+			want:  []service.ImportStatus{service.ImportRejected, service.ImportCreated},
+			lines: []int{2, 3},
+		},
+		{
+			name:   "JSONL",
+			format: service.ImportJSONL,
+			feed: `{"title": "Naruto", "price": 9.99, "stock": 3}` + "\n\n" +
+				`{"title": "", "price": 1}` + "\n" +
+				`{"title": "Bleach", "price": "4.5"` + "\n",
+			want:  []service.ImportStatus{service.ImportCreated, service.ImportRejected, service.ImportRejected},
+			lines: []int{1, 3, 4},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bookService, err := service.NewBookService(&upsertBookRepository{titles: map[string]int64{}})
+			require.NoError(t, err)
+
+			report, err := bookService.ImportBooks(context.Background(), strings.NewReader(c.feed), c.format)
+			require.NoError(t, err)
+
+			var statuses []service.ImportStatus
+			var lines []int
+			for _, line := range report.Lines {
+				statuses = append(statuses, line.Status)
+				lines = append(lines, line.Line)
+			}
+			require.Equal(t, c.want, statuses)
+			require.Equal(t, c.lines, lines)
+		})
+	}
+
+	t.Run("Feed Breaks Off", func(t *testing.T) {
+		bookService, err := service.NewBookService(&upsertBookRepository{titles: map[string]int64{}})
+		require.NoError(t, err)
+
+		feed := io.MultiReader(
+			strings.NewReader("title,price,stock,cover_image,synopsis\nNaruto,9.99,10,,\nBleach,abc,1,,\nOne Pie"),
+			iotest.ErrReader(errors.New("connection reset")),
+		)
+		res := httptest.NewRecorder()
+		controller.NewBookHandler(bookService).ImportBooks(res, httptest.NewRequest(http.MethodPost, "/books/import?format=csv", feed))
+		require.Equal(t, http.StatusBadRequest, res.Code)
+
+		// The rows read before the error are reported, they are saved already
+		var report struct {
+			Created   int  `json:"created"`
+			Rejected  int  `json:"rejected"`
+			Truncated bool `json:"truncated"`
+			Lines     []struct {
+				Line   int    `json:"line"`
+				Status string `json:"status"`
+			} `json:"lines"`
+			Error string `json:"error"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&report))
+		require.True(t, report.Truncated)
+		require.Contains(t, report.Error, "connection reset")
+		require.Equal(t, 1, report.Created)
+		require.Equal(t, 1, report.Rejected)
+		require.Len(t, report.Lines, 2)
+		require.Equal(t, 3, report.Lines[1].Line)
+	})
+
+	t.Run("Missing Column", func(t *testing.T) {
+		bookService, err := service.NewBookService(&upsertBookRepository{titles: map[string]int64{}})
+		require.NoError(t, err)
+
+		_, err = bookService.ImportBooks(context.Background(), strings.NewReader("title,price\nNaruto,1\n"), service.ImportCSV)
+		require.ErrorIs(t, err, service.ErrImportHeader)
+	})
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bookstore_api/tools"
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	legacyCiphertext, err := tools.Encrypt([]byte("cover.jpg"), oldKey)
+	require.NoError(t, err)
+
+	keyring, err := tools.NewKeyring("2025q1", map[string][]byte{
+		tools.LegacyKeyID: oldKey,
+		"2025q1":          newKey,
+	})
+	require.NoError(t, err)
+
+	t.Run("Decrypts ciphertext from before the keyring", func(t *testing.T) {
+		plaintext, err := keyring.Decrypt(legacyCiphertext)
+		require.NoError(t, err)
+		require.Equal(t, "cover.jpg", string(plaintext))
+		require.True(t, keyring.NeedsRotation(legacyCiphertext))
+	})
+
+	t.Run("Rotates to the current key", func(t *testing.T) {
+		rotated, err := keyring.Rotate(legacyCiphertext)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(rotated, "v1:2025q1:"))
+		require.False(t, keyring.NeedsRotation(rotated))
+
+		plaintext, err := keyring.Decrypt(rotated)
+		require.NoError(t, err)
+		require.Equal(t, "cover.jpg", string(plaintext))
+
+		again, err := keyring.Rotate(rotated)
+		require.NoError(t, err)
+		require.Equal(t, rotated, again)
+	})
+
+	t.Run("Refuses keys it doesn't hold", func(t *testing.T) {
+		_, err := keyring.Decrypt("v1:2024q4:" + legacyCiphertext)
+		require.ErrorIs(t, err, tools.ErrUnknownKey)
+	})
+}
+
+func TestKeyringFromEnv(t *testing.T) {
+	first := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	second := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 16))
+
+	t.Run("Encrypts with the last key listed", func(t *testing.T) {
+		t.Setenv("AES_KEY", "")
+		t.Setenv("AES_KEY_ID", "")
+		t.Setenv("AES_KEYS", "a:"+first+", b:"+second)
+
+		keyring, err := tools.KeyringFromEnv()
+		require.NoError(t, err)
+		require.Equal(t, "b", keyring.CurrentKeyID())
+	})
+
+	t.Run("Encrypts with the key picked", func(t *testing.T) {
+		t.Setenv("AES_KEY", first)
+		t.Setenv("AES_KEY_ID", tools.LegacyKeyID)
+		t.Setenv("AES_KEYS", "b:"+second)
+
+		keyring, err := tools.KeyringFromEnv()
+		require.NoError(t, err)
+		require.Equal(t, tools.LegacyKeyID, keyring.CurrentKeyID())
+	})
+
+	t.Run("Rejects a malformed list", func(t *testing.T) {
+		t.Setenv("AES_KEY", "")
+		t.Setenv("AES_KEY_ID", "")
+		t.Setenv("AES_KEYS", first)
+
+		_, err := tools.KeyringFromEnv()
+		require.ErrorIs(t, err, tools.ErrKeyringConfig)
+	})
+
+	t.Run("Rejects keys of the wrong size", func(t *testing.T) {
+		t.Setenv("AES_KEY", "")
+		t.Setenv("AES_KEY_ID", "")
+		t.Setenv("AES_KEYS", "a:"+base64.StdEncoding.EncodeToString([]byte("short")))
+
+		_, err := tools.KeyringFromEnv()
+		require.ErrorIs(t, err, tools.ErrInvalidKeySize)
+	})
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"bookstore_api/tools"
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMasterKeys(t *testing.T, name string, lines ...string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(name, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+}
+
+func TestEnvelope(t *testing.T) {
+	masterKeyFile := filepath.Join(t.TempDir(), "master.key")
+	first := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	second := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	writeMasterKeys(t, masterKeyFile, "# development keys", "m1:"+first)
+
+	keyManager, err := tools.NewLocalKeyManager(masterKeyFile)
+	require.NoError(t, err)
+
+	legacyKey := bytes.Repeat([]byte{3}, 32)
+	fallback, err := tools.NewKeyring(tools.LegacyKeyID, map[string][]byte{tools.LegacyKeyID: legacyKey})
+	require.NoError(t, err)
+
+	envelope := tools.NewEnvelope(keyManager, fallback)
+
+	ciphertext, err := envelope.Encrypt([]byte("cover.jpg"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(ciphertext, "v2:m1:"))
+	require.False(t, envelope.NeedsRotation(ciphertext))
+
+	t.Run("Decrypts its own ciphertext", func(t *testing.T) {
+		plaintext, err := envelope.Decrypt(ciphertext)
+		require.NoError(t, err)
+		require.Equal(t, "cover.jpg", string(plaintext))
+	})
+
+	t.Run("Reads and rotates keyring ciphertext", func(t *testing.T) {
+		legacyCiphertext, err := tools.Encrypt([]byte("old.jpg"), legacyKey)
+		require.NoError(t, err)
+		require.True(t, envelope.NeedsRotation(legacyCiphertext))
+
+		rotated, err := envelope.Rotate(legacyCiphertext)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(rotated, "v2:m1:"))
+
+		plaintext, err := envelope.Decrypt(rotated)
+		require.NoError(t, err)
+		require.Equal(t, "old.jpg", string(plaintext))
+	})
+
+	t.Run("Rotates to a new master key", func(t *testing.T) {
+		writeMasterKeys(t, masterKeyFile, "m1:"+first, "m2:"+second)
+		rotatedManager, err := tools.NewLocalKeyManager(masterKeyFile)
+		require.NoError(t, err)
+		require.Equal(t, "m2", rotatedManager.KeyID())
+
+		rotatedEnvelope := tools.NewEnvelope(rotatedManager, nil)
+		require.True(t, rotatedEnvelope.NeedsRotation(ciphertext))
+
+		rotated, err := rotatedEnvelope.Rotate(ciphertext)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(rotated, "v2:m2:"))
+
+		plaintext, err := rotatedEnvelope.Decrypt(rotated)
+		require.NoError(t, err)
+		require.Equal(t, "cover.jpg", string(plaintext))
+	})
+
+	t.Run("Refuses master keys it doesn't hold", func(t *testing.T) {
+		_, err := envelope.Decrypt(strings.Replace(ciphertext, "v2:m1:", "v2:m9:", 1))
+		require.ErrorIs(t, err, tools.ErrUnknownKey)
+	})
+}
+
+func TestCipherFromEnv(t *testing.T) {
+	masterKeyFile := filepath.Join(t.TempDir(), "master.key")
+	writeMasterKeys(t, masterKeyFile, "m1:"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+
+	t.Setenv("AES_KEYS", "")
+	t.Setenv("AES_KEY_ID", "")
+	t.Setenv("AES_KEY", "")
+	t.Setenv("KMS_MASTER_KEY_FILE", masterKeyFile)
+
+	cipher, err := tools.CipherFromEnv()
+	require.NoError(t, err)
+	require.IsType(t, &tools.Envelope{}, cipher)
+	require.Equal(t, "m1", cipher.CurrentKeyID())
+
+	t.Setenv("KMS_MASTER_KEY_FILE", filepath.Join(t.TempDir(), "missing.key"))
+	_, err = tools.CipherFromEnv()
+	require.Error(t, err)
+}
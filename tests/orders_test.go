@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"bookstore_api/internal/core/domain/orders"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOrderPrice(t *testing.T) {
+	address, err := addresses.NewAddress("Jl. Sudirman 1", "Jakarta", "10220", "ID")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name string
+		test func(*testing.T, *orders.Order)
+	}{
+		{
+			name: "Success",
+			test: func(t *testing.T, order *orders.Order) {
+				require.NoError(t, order.AddLine(3, 2))
+				require.NoError(t, order.AddLine(5, 1))
+
+				err := order.Price(map[int64]float64{3: 12.99, 5: 10.49})
+				require.NoError(t, err)
+				require.Equal(t, 36.47, order.ProductPrice.Get())
+				require.Equal(t, 4.01, order.TaxFee.Get())
+				require.Equal(t, 40.48, order.TotalPrice.Get())
+			},
+		},
+		{
+			name: "Merges Duplicate Books",
+			test: func(t *testing.T, order *orders.Order) {
+				require.NoError(t, order.AddLine(3, 2))
+				require.NoError(t, order.AddLine(3, 3))
+				require.Len(t, order.Lines, 1)
+				require.Equal(t, int64(5), order.Lines[0].Quantity.Get())
+			},
+		},
+		{
+			name: "Invalid Quantity",
+			test: func(t *testing.T, order *orders.Order) {
+				require.ErrorIs(t, order.AddLine(3, 0), orders.ErrInvalidQuantity)
+			},
+		},
+		{
+			name: "Empty Order",
+			test: func(t *testing.T, order *orders.Order) {
+				require.ErrorIs(t, order.Price(map[int64]float64{}), orders.ErrEmptyOrder)
+			},
+		},
+		{
+			name: "Missing Price",
+			test: func(t *testing.T, order *orders.Order) {
+				require.NoError(t, order.AddLine(3, 1))
+				require.ErrorIs(t, order.Price(map[int64]float64{}), orders.ErrMissingPrice)
+			},
+		},
+		{
+			name: "Cancel Only Once",
+			test: func(t *testing.T, order *orders.Order) {
+				require.NoError(t, order.Cancel())
+				require.Equal(t, orders.Canceled, order.Status)
+				require.ErrorIs(t, order.Cancel(), orders.ErrNotCancelable)
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			order, err := orders.NewOrder(1, address, "QRIS")
+			require.NoError(t, err)
+			c.test(t, order)
+		})
+	}
+}
+
+func TestNewAddress(t *testing.T) {
+	cases := []struct {
+		name       string
+		postalCode string
+		country    string
+		err        error
+	}{
+		{name: "Indonesia", postalCode: "10220", country: "id"},
+		{name: "Japan", postalCode: "150-0002", country: "JP"},
+		{name: "Generic Format", postalCode: "1010", country: "AT"},
+		{name: "Bad Postal Code", postalCode: "1022", country: "ID", err: addresses.ErrInvalidPostalCode},
+		{name: "Country Name", postalCode: "10220", country: "Indonesia", err: addresses.ErrInvalidCountry},
+		{name: "Unknown Country", postalCode: "10220", country: "XX", err: addresses.ErrInvalidCountry},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			address, err := addresses.NewAddress("Jl. Sudirman 1", "Jakarta", c.postalCode, c.country)
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, address.Country.Get(), 2)
+		})
+	}
+}
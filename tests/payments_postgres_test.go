@@ -0,0 +1,300 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/payments"
+	"bookstore_api/internal/core/service"
+	"bookstore_api/internal/infrastructure/http/route"
+	"bookstore_api/internal/infrastructure/payment"
+	"bookstore_api/internal/infrastructure/postgres"
+	"bookstore_api/tools"
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// withPaymentRepositoryMock hands out a payment repository over a mocked database, queries are matched as regular expressions
+func withPaymentRepositoryMock(t *testing.T, fn func(*postgres.PaymentRepository, sqlmock.Sqlmock)) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fn(postgres.NewPaymentRepository(postgres.NewWithDB(sqlx.NewDb(conn, "sqlmock"))), mock)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// newPendingPayment returns a stored pending payment of order 3
+func newPendingPayment(t *testing.T) *payments.Payment {
+	address, err := addresses.NewAddress("Jl. Sudirman 1", "Jakarta", "10220", "ID")
+	require.NoError(t, err)
+	order, err := orders.NewOrder(1, address, "PayPal")
+	require.NoError(t, err)
+	require.NoError(t, order.Create(3))
+
+	payment, err := payments.NewPayment(order, "reader@example.com")
+	require.NoError(t, err)
+	require.NoError(t, payment.Create(9))
+	payment.ProviderReference = "fake_9"
+
+	return payment
+}
+
+func TestCompletePayment(t *testing.T) {
+	updatePayment := regexp.QuoteMeta("UPDATE paymentresults")
+	updateOrder := regexp.QuoteMeta("UPDATE orders")
+
+	t.Run("Captures Once Payable", func(t *testing.T) {
+		withPaymentRepositoryMock(t, func(r *postgres.PaymentRepository, mock sqlmock.Sqlmock) {
+			payment := newPendingPayment(t)
+			require.NoError(t, payment.Complete())
+
+			mock.ExpectBegin()
+			mock.ExpectExec(updatePayment).
+				WithArgs("completed", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), int64(9), "pending").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(updateOrder).
+				WithArgs("paid", sqlmock.AnyArg(), int64(3), "placed").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			captured := false
+			_, err := r.Complete(context.Background(), payment, func(context.Context) error {
+				captured = true
+				return nil
+			})
+			require.NoError(t, err)
+			require.True(t, captured)
+		})
+	})
+
+	t.Run("Canceled Order", func(t *testing.T) {
+		withPaymentRepositoryMock(t, func(r *postgres.PaymentRepository, mock sqlmock.Sqlmock) {
+			payment := newPendingPayment(t)
+			require.NoError(t, payment.Complete())
+
+			mock.ExpectBegin()
+			mock.ExpectExec(updatePayment).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(updateOrder).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			_, err := r.Complete(context.Background(), payment, func(context.Context) error {
+				t.Fatal("the payment of a canceled order must not be captured")
+				return nil
+			})
+			require.ErrorIs(t, err, payments.ErrOrderNotPayable)
+		})
+	})
+
+	t.Run("Failed Capture", func(t *testing.T) {
+		withPaymentRepositoryMock(t, func(r *postgres.PaymentRepository, mock sqlmock.Sqlmock) {
+			payment := newPendingPayment(t)
+			require.NoError(t, payment.Complete())
+
+			mock.ExpectBegin()
+			mock.ExpectExec(updatePayment).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(updateOrder).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectRollback()
+
+			declined := errors.New("card declined")
+			_, err := r.Complete(context.Background(), payment, func(context.Context) error {
+				return declined
+			})
+			require.ErrorIs(t, err, declined)
+		})
+	})
+}
+
+func TestCancelOrderCancelsPendingPayment(t *testing.T) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	r := postgres.NewOrderRepository(postgres.NewWithDB(sqlx.NewDb(conn, "sqlmock")))
+
+	address, err := addresses.NewAddress("Jl. Sudirman 1", "Jakarta", "10220", "ID")
+	require.NoError(t, err)
+	order, err := orders.NewOrder(1, address, "PayPal")
+	require.NoError(t, err)
+	require.NoError(t, order.Create(3))
+	require.NoError(t, order.Cancel())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE orders")).
+		WithArgs("canceled", sqlmock.AnyArg(), int64(3), "placed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE paymentresults p")).
+		WithArgs("canceled", sqlmock.AnyArg(), int64(3), "pending").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE books b")).
+		WithArgs(int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	_, err = r.Cancel(context.Background(), order)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookAfterCancel(t *testing.T) {
+	insertEvent := regexp.QuoteMeta("INSERT INTO paymentevents")
+
+	t.Run("Recorded Without Applying", func(t *testing.T) {
+		withPaymentRepositoryMock(t, func(r *postgres.PaymentRepository, mock sqlmock.Sqlmock) {
+			// Canceling the order canceled its payment, so the completed event doesn't move it
+			payment := newPendingPayment(t)
+			require.NoError(t, payment.Cancel())
+
+			event, err := payments.NewEvent("evt_1", payment.Method, payment.ProviderReference, "completed")
+			require.NoError(t, err)
+			changed, err := payment.Apply(event.Status)
+			require.NoError(t, err)
+			require.False(t, changed)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(insertEvent).
+				WithArgs(payment.Method.Get(), "evt_1", int64(9), "completed", false).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			recorded, err := r.RecordEvent(context.Background(), event, payment, changed)
+			require.NoError(t, err)
+			require.True(t, recorded)
+			require.Equal(t, payments.Canceled, payment.Status)
+		})
+	})
+
+	t.Run("Order Canceled Meanwhile", func(t *testing.T) {
+		withPaymentRepositoryMock(t, func(r *postgres.PaymentRepository, mock sqlmock.Sqlmock) {
+			// The payment was read as pending right before the order got canceled
+			payment := newPendingPayment(t)
+			event, err := payments.NewEvent("evt_1", payment.Method, payment.ProviderReference, "completed")
+			require.NoError(t, err)
+			changed, err := payment.Apply(event.Status)
+			require.NoError(t, err)
+			require.True(t, changed)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(insertEvent).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE paymentresults")).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE orders")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			// Nothing is recorded, the redelivered event finds the canceled payment
+			_, err = r.RecordEvent(context.Background(), event, payment, changed)
+			require.ErrorIs(t, err, payments.ErrOrderNotPayable)
+		})
+	})
+}
+
+func TestWebhookRouteNeedsSecret(t *testing.T) {
+	t.Setenv("PAYMENT_WEBHOOK_SECRET", "")
+
+	router := route.NewRouter(newMemoryDenylist())
+	router.RegisterPaymentRoutes(nil, nil)
+
+	res := httptest.NewRecorder()
+	router.Mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/webhooks/payments/PayPal", strings.NewReader("{}")))
+	require.Equal(t, http.StatusNotFound, res.Code)
+}
+
+// recordingProvider settles payments like the fake provider and remembers the calls it got
+type recordingProvider struct {
+	*payment.FakeProvider
+	calls []string
+}
+
+func (p *recordingProvider) Capture(ctx context.Context, reference string) error {
+	p.calls = append(p.calls, "capture")
+	return p.FakeProvider.Capture(ctx, reference)
+}
+
+func (p *recordingProvider) Void(ctx context.Context, reference string) error {
+	p.calls = append(p.calls, "void")
+	return p.FakeProvider.Void(ctx, reference)
+}
+
+func TestPaymentCommitFails(t *testing.T) {
+	columns := []string{
+		"id", "order_id", "user_id", "payment_method", "amount", "status", "email_address", "provider_reference",
+		"created_at", "updated_at", "completed_at", "canceled_at",
+	}
+	ctx := tools.WithPrincipal(context.Background(), &tools.Principal{UserID: 1, Email: "reader@example.com"})
+
+	// run loads the pending payment 9 and lets the commit of its transition fail
+	run := func(t *testing.T, expect func(sqlmock.Sqlmock), fn func(*service.PaymentService) error) []string {
+		var calls []string
+		withPaymentRepositoryMock(t, func(r *postgres.PaymentRepository, mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("WHERE p.id = $1")).
+				WithArgs(9).
+				WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(9, 3, 1, "PayPal", 12.5, "pending", "reader@example.com", "fake_9", nil, nil, nil, nil))
+			mock.ExpectBegin()
+			expect(mock)
+			mock.ExpectCommit().WillReturnError(errors.New("connection reset"))
+
+			provider := &recordingProvider{FakeProvider: payment.NewFakeProvider(orders.PayPal)}
+			err := fn(service.NewPaymentService(r, nil, provider))
+			require.Error(t, err)
+			calls = provider.calls
+		})
+		return calls
+	}
+
+	t.Run("Capture Is Voided", func(t *testing.T) {
+		calls := run(t, func(mock sqlmock.Sqlmock) {
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE paymentresults")).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE orders")).WillReturnResult(sqlmock.NewResult(0, 1))
+		}, func(s *service.PaymentService) error {
+			_, err := s.ConfirmPayment(ctx, 9)
+			return err
+		})
+		require.Equal(t, []string{"capture", "void"}, calls)
+	})
+
+	t.Run("Void Runs In The Transaction", func(t *testing.T) {
+		calls := run(t, func(mock sqlmock.Sqlmock) {
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE paymentresults")).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE orders")).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE books b")).WillReturnResult(sqlmock.NewResult(0, 1))
+		}, func(s *service.PaymentService) error {
+			_, err := s.CancelPayment(ctx, 9)
+			return err
+		})
+		require.Equal(t, []string{"void"}, calls)
+	})
+
+	t.Run("Lost Race Leaves The Provider Alone", func(t *testing.T) {
+		withPaymentRepositoryMock(t, func(r *postgres.PaymentRepository, mock sqlmock.Sqlmock) {
+			mock.ExpectQuery(regexp.QuoteMeta("WHERE p.id = $1")).
+				WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(9, 3, 1, "PayPal", 12.5, "pending", "reader@example.com", "fake_9", nil, nil, nil, nil))
+			mock.ExpectBegin()
+			// A confirm got there first
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE paymentresults")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			provider := &recordingProvider{FakeProvider: payment.NewFakeProvider(orders.PayPal)}
+			_, err := service.NewPaymentService(r, nil, provider).CancelPayment(ctx, 9)
+			require.ErrorIs(t, err, payments.ErrInvalidTransition)
+			require.Empty(t, provider.calls)
+		})
+	})
+}
+
+func TestFakeProviderRefundsCapture(t *testing.T) {
+	provider := payment.NewFakeProvider(orders.PayPal)
+	reference, err := provider.Initiate(context.Background(), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, provider.Capture(context.Background(), reference))
+	require.NoError(t, provider.Void(context.Background(), reference))
+	require.ErrorIs(t, provider.Capture(context.Background(), reference), payment.ErrAlreadySettled)
+}
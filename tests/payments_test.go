@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/addresses"
+	"bookstore_api/internal/core/domain/orders"
+	"bookstore_api/internal/core/domain/payments"
+	"bookstore_api/tools"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPaymentTransitions(t *testing.T) {
+	cases := []struct {
+		name string
+		test func(*testing.T, *payments.Payment)
+	}{
+		{
+			name: "Complete",
+			test: func(t *testing.T, payment *payments.Payment) {
+				require.NoError(t, payment.Complete())
+				require.Equal(t, payments.Completed, payment.Status)
+				require.NotNil(t, payment.CompletedAt)
+			},
+		},
+		{
+			name: "Cancel",
+			test: func(t *testing.T, payment *payments.Payment) {
+				require.NoError(t, payment.Cancel())
+				require.Equal(t, payments.Canceled, payment.Status)
+				require.NotNil(t, payment.CanceledAt)
+			},
+		},
+		{
+			name: "No Reopening",
+			test: func(t *testing.T, payment *payments.Payment) {
+				require.NoError(t, payment.Complete())
+				require.ErrorIs(t, payment.Cancel(), payments.ErrInvalidTransition)
+				require.ErrorIs(t, payment.Complete(), payments.ErrInvalidTransition)
+				require.Equal(t, payments.Completed, payment.Status)
+			},
+		},
+		{
+			name: "Out Of Order Events",
+			test: func(t *testing.T, payment *payments.Payment) {
+				changed, err := payment.Apply(payments.Completed)
+				require.NoError(t, err)
+				require.True(t, changed)
+
+				for _, status := range []payments.Status{payments.Pending, payments.Canceled, payments.Completed} {
+					changed, err = payment.Apply(status)
+					require.NoError(t, err)
+					require.False(t, changed)
+					require.Equal(t, payments.Completed, payment.Status)
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			address, err := addresses.NewAddress("Jl. Sudirman 1", "Jakarta", "10220", "ID")
+			require.NoError(t, err)
+			order, err := orders.NewOrder(1, address, "PayPal")
+			require.NoError(t, err)
+
+			payment, err := payments.NewPayment(order, "reader@example.com")
+			require.NoError(t, err)
+			require.Equal(t, payments.Pending, payment.Status)
+			c.test(t, payment)
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"id":"evt_1","reference":"fake_1","status":"completed"}`)
+
+	require.True(t, tools.VerifySignature(body, tools.Sign(body, secret), secret))
+	require.False(t, tools.VerifySignature(body, tools.Sign(body, []byte("other")), secret))
+	require.False(t, tools.VerifySignature(append(body, ' '), tools.Sign(body, secret), secret))
+	require.False(t, tools.VerifySignature(body, "", secret))
+}
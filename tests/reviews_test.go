@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"bookstore_api/internal/core/domain/reviews"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestNewReview(t *testing.T) {
+	cases := []struct {
+		name    string
+		rating  int
+		comment string
+		err     error
+	}{
+		{name: "Success", rating: 5, comment: "Couldn't put it down."},
+		{name: "Rating Too Low", rating: 0, err: reviews.ErrInvalidRating},
+		{name: "Rating Too High", rating: 6, err: reviews.ErrInvalidRating},
+		{name: "Comment At Limit", rating: 3, comment: strings.Repeat("本", reviews.MaxCommentLength)},
+		{name: "Comment Too Long", rating: 3, comment: strings.Repeat("a", reviews.MaxCommentLength+1), err: reviews.ErrLongComment},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			review, err := reviews.NewReview(c.rating, c.comment)
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.rating, review.Rating.Get())
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package tests
+
+import (
+	"bookstore_api/internal/repositories"
+	"bookstore_api/internal/services"
+	"bookstore_api/models"
+	"bookstore_api/tools"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// memorySessionRepository keeps sessions and refresh token hashes in maps
+type memorySessionRepository struct {
+	sessions map[string]*models.Sessions
+	tokens   map[string]*models.RefreshToken
+}
+
+func newMemorySessionRepository() *memorySessionRepository {
+	return &memorySessionRepository{
+		sessions: map[string]*models.Sessions{},
+		tokens:   map[string]*models.RefreshToken{},
+	}
+}
+
+func (r *memorySessionRepository) Create(_ context.Context, session *models.Sessions, tokenHash string) (*models.Sessions, error) {
+	r.sessions[session.ID] = session
+	r.tokens[tokenHash] = &models.RefreshToken{TokenHash: tokenHash, SessionID: session.ID, CreatedAt: time.Now()}
+	return session, nil
+}
+
+func (r *memorySessionRepository) Get(_ context.Context, id string) (*models.Sessions, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, errors.New("error getting session: not found")
+	}
+	return session, nil
+}
+
+func (r *memorySessionRepository) GetRefreshToken(_ context.Context, tokenHash string) (*models.RefreshToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, repositories.ErrRefreshTokenNotFound
+	}
+	return token, nil
+}
+
+func (r *memorySessionRepository) RotateRefreshToken(_ context.Context, sessionID string, oldHash string, newHash string) (bool, error) {
+	token, ok := r.tokens[oldHash]
+	if !ok || token.SessionID != sessionID || token.RotatedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.RotatedAt = &now
+	r.tokens[newHash] = &models.RefreshToken{TokenHash: newHash, SessionID: sessionID, CreatedAt: now}
+	return true, nil
+}
+
+func (r *memorySessionRepository) Revoke(_ context.Context, id string) error {
+	if session, ok := r.sessions[id]; ok {
+		session.IsRevoked = true
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) ListActive(_ context.Context, email string) ([]*models.Sessions, error) {
+	var sessions []*models.Sessions
+	for _, session := range r.sessions {
+		if session.UserEmail == email && !session.IsRevoked && session.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) RevokeForUser(_ context.Context, email string, id string) (bool, error) {
+	session, ok := r.sessions[id]
+	if !ok || session.UserEmail != email || session.IsRevoked {
+		return false, nil
+	}
+	session.IsRevoked = true
+	return true, nil
+}
+
+func (r *memorySessionRepository) RevokeAll(_ context.Context, email string, exceptID string) ([]string, error) {
+	var revoked []string
+	for id, session := range r.sessions {
+		if session.UserEmail == email && id != exceptID && !session.IsRevoked {
+			session.IsRevoked = true
+			revoked = append(revoked, id)
+		}
+	}
+	return revoked, nil
+}
+
+func (r *memorySessionRepository) Delete(_ context.Context, id string) error {
+	delete(r.sessions, id)
+	return nil
+}
+
+func (r *memorySessionRepository) DeleteInactive(_ context.Context, limit int) (int64, int64, error) {
+	var revoked, expired int64
+	for id, session := range r.sessions {
+		if revoked+expired == int64(limit) {
+			break
+		}
+		switch {
+		case session.IsRevoked:
+			revoked++
+		case !session.ExpiresAt.After(time.Now()):
+			expired++
+		default:
+			continue
+		}
+		delete(r.sessions, id)
+	}
+	return revoked, expired, nil
+}
+
+// memoryDenylist keeps denied token IDs and the tokens of each session in maps, nothing ever expires
+type memoryDenylist struct {
+	denied   map[string]bool
+	sessions map[string][]string
+}
+
+func newMemoryDenylist() *memoryDenylist {
+	return &memoryDenylist{
+		denied:   map[string]bool{},
+		sessions: map[string][]string{},
+	}
+}
+
+func (d *memoryDenylist) Track(_ context.Context, sessionID string, tokenID string, _ time.Time) error {
+	d.sessions[sessionID] = append(d.sessions[sessionID], tokenID)
+	return nil
+}
+
+func (d *memoryDenylist) DenySession(_ context.Context, sessionID string) error {
+	for _, tokenID := range d.sessions[sessionID] {
+		d.denied[tokenID] = true
+	}
+	delete(d.sessions, sessionID)
+	return nil
+}
+
+func (d *memoryDenylist) IsDenied(_ context.Context, tokenID string) (bool, error) {
+	return d.denied[tokenID], nil
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	ctx := context.Background()
+	repo := newMemorySessionRepository()
+	sessionService := services.NewSessionService(&services.Service{}, repo, nil)
+
+	session, _, firstToken, err := sessionService.CreateSession(ctx, &tools.Principal{UserID: 7, Email: "user@example.com"}, "curl/8.0", "127.0.0.1")
+	require.NoError(t, err)
+	for hash := range repo.tokens {
+		require.NotEqual(t, firstToken, hash)
+	}
+
+	rotatedSession, claims, secondToken, err := sessionService.RotateRefreshToken(ctx, firstToken)
+	require.NoError(t, err)
+	require.Equal(t, session.ID, rotatedSession.ID)
+	require.Equal(t, session.ID, claims.SessionID)
+	require.NotEqual(t, firstToken, secondToken)
+	require.False(t, claims.ExpiresAt.Time.After(session.ExpiresAt))
+
+	_, err = sessionService.GetSessionByRefreshToken(ctx, firstToken)
+	require.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+
+	// The first token coming back means it leaked, the whole session goes
+	_, _, _, err = sessionService.RotateRefreshToken(ctx, firstToken)
+	require.ErrorIs(t, err, services.ErrRefreshTokenReused)
+	require.True(t, repo.sessions[session.ID].IsRevoked)
+
+	_, _, _, err = sessionService.RotateRefreshToken(ctx, secondToken)
+	require.ErrorIs(t, err, services.ErrSessionRevoked)
+
+	_, _, _, err = sessionService.RotateRefreshToken(ctx, "not a token")
+	require.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+}
+
+func TestRefreshRefusesAccessTokens(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	ctx := context.Background()
+	sessionService := services.NewSessionService(&services.Service{}, newMemorySessionRepository(), newMemoryDenylist())
+
+	session, _, _, err := sessionService.CreateSession(ctx, &tools.Principal{UserID: 7, Email: "user@example.com"}, "curl/8.0", "127.0.0.1")
+	require.NoError(t, err)
+
+	_, accessToken, err := sessionService.IssueAccessToken(ctx, &tools.Principal{UserID: 7, Email: "user@example.com", SessionID: session.ID}, time.Minute)
+	require.NoError(t, err)
+
+	_, _, _, err = sessionService.RotateRefreshToken(ctx, accessToken)
+	require.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+	_, err = sessionService.GetSessionByRefreshToken(ctx, accessToken)
+	require.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+}
+
+func TestManageSessions(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	repo := newMemorySessionRepository()
+	sessionService := services.NewSessionService(&services.Service{}, repo, nil)
+
+	principal := &tools.Principal{UserID: 7, Email: "user@example.com"}
+	laptop, _, _, err := sessionService.CreateSession(context.Background(), principal, "Firefox", "10.0.0.1")
+	require.NoError(t, err)
+	phone, _, _, err := sessionService.CreateSession(context.Background(), principal, "Safari", "10.0.0.2")
+	require.NoError(t, err)
+	tablet, _, _, err := sessionService.CreateSession(context.Background(), principal, "Chrome", "10.0.0.3")
+	require.NoError(t, err)
+	other, _, _, err := sessionService.CreateSession(context.Background(), &tools.Principal{UserID: 8, Email: "other@example.com"}, "Edge", "10.0.0.4")
+	require.NoError(t, err)
+
+	current := *principal
+	current.SessionID = laptop.ID
+	ctx := tools.WithPrincipal(context.Background(), &current)
+
+	sessions, err := sessionService.ListSessions(ctx)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	for _, session := range sessions {
+		require.Equal(t, session.ID == laptop.ID, session.Current)
+	}
+
+	t.Run("Revokes one of their own", func(t *testing.T) {
+		require.NoError(t, sessionService.RevokeUserSession(ctx, phone.ID))
+		require.True(t, repo.sessions[phone.ID].IsRevoked)
+
+		err := sessionService.RevokeUserSession(ctx, other.ID)
+		require.ErrorIs(t, err, services.ErrSessionNotFound)
+		require.False(t, repo.sessions[other.ID].IsRevoked)
+	})
+
+	t.Run("Signs out everywhere else", func(t *testing.T) {
+		revoked, err := sessionService.RevokeOtherSessions(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), revoked)
+		require.True(t, repo.sessions[tablet.ID].IsRevoked)
+		require.False(t, repo.sessions[laptop.ID].IsRevoked)
+		require.False(t, repo.sessions[other.ID].IsRevoked)
+	})
+}
+
+func TestSweepSessions(t *testing.T) {
+	repo := newMemorySessionRepository()
+	sessionService := services.NewSessionService(&services.Service{}, repo, nil)
+
+	now := time.Now()
+	for i, session := range []*models.Sessions{
+		{ID: "active", ExpiresAt: now.Add(time.Hour)},
+		{ID: "revoked-1", IsRevoked: true, ExpiresAt: now.Add(time.Hour)},
+		{ID: "revoked-2", IsRevoked: true, ExpiresAt: now.Add(time.Hour)},
+		{ID: "expired-1", ExpiresAt: now.Add(-time.Hour)},
+		{ID: "expired-2", ExpiresAt: now.Add(-time.Minute)},
+		{ID: "expired-3", ExpiresAt: now.Add(-time.Second)},
+	} {
+		_, err := repo.Create(context.Background(), session, string(rune('a'+i)))
+		require.NoError(t, err)
+	}
+
+	// A batch smaller than what there is to delete takes a few rounds
+	report, err := sessionService.SweepSessions(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, &services.SweepReport{Revoked: 2, Expired: 3}, report)
+	require.Len(t, repo.sessions, 1)
+	require.Contains(t, repo.sessions, "active")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sessionService.SweepSessions(ctx, 2)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLogoutDeniesAccessTokens(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	ctx := context.Background()
+	denylist := newMemoryDenylist()
+	sessionService := services.NewSessionService(&services.Service{}, newMemorySessionRepository(), denylist)
+
+	principal := &tools.Principal{UserID: 7, Email: "user@example.com"}
+	laptop, _, _, err := sessionService.CreateSession(ctx, principal, "Firefox", "10.0.0.1")
+	require.NoError(t, err)
+	phone, _, _, err := sessionService.CreateSession(ctx, principal, "Safari", "10.0.0.2")
+	require.NoError(t, err)
+
+	issue := func(sessionID string) string {
+		sessionPrincipal := *principal
+		sessionPrincipal.SessionID = sessionID
+		claims, _, err := sessionService.IssueAccessToken(ctx, &sessionPrincipal, time.Minute)
+		require.NoError(t, err)
+		return claims.RegisteredClaims.ID
+	}
+	laptopToken, phoneToken := issue(laptop.ID), issue(phone.ID)
+
+	require.NoError(t, sessionService.DeleteSession(ctx, laptop.ID))
+	require.True(t, denylist.denied[laptopToken])
+	require.False(t, denylist.denied[phoneToken])
+
+	require.NoError(t, sessionService.RevokeAllSessions(ctx, principal.Email))
+	require.True(t, denylist.denied[phoneToken])
+}
+
+// racingDenylist runs beforeTrack right before a token is tracked, like a revocation landing just then
+type racingDenylist struct {
+	*memoryDenylist
+	beforeTrack func()
+}
+
+func (d *racingDenylist) Track(ctx context.Context, sessionID string, tokenID string, expiresAt time.Time) error {
+	if d.beforeTrack != nil {
+		d.beforeTrack()
+	}
+	return d.memoryDenylist.Track(ctx, sessionID, tokenID, expiresAt)
+}
+
+func TestRevokeWhileIssuingAccessToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("ISSUER", "bookstore")
+
+	ctx := context.Background()
+	denylist := &racingDenylist{memoryDenylist: newMemoryDenylist()}
+	sessionService := services.NewSessionService(&services.Service{}, newMemorySessionRepository(), denylist)
+
+	principal := &tools.Principal{UserID: 7, Email: "user@example.com"}
+	session, _, refreshToken, err := sessionService.CreateSession(ctx, principal, "Firefox", "10.0.0.1")
+	require.NoError(t, err)
+
+	_, claims, _, err := sessionService.RotateRefreshToken(ctx, refreshToken)
+	require.NoError(t, err)
+
+	// The session passed the refresh, then gets revoked before its new access token is tracked
+	denylist.beforeTrack = func() {
+		require.NoError(t, sessionService.RevokeSession(ctx, session.ID))
+	}
+	_, _, err = sessionService.IssueAccessToken(ctx, claims.Principal(), time.Minute)
+	require.ErrorIs(t, err, services.ErrSessionRevoked)
+
+	require.Empty(t, denylist.sessions[session.ID])
+	require.Len(t, denylist.denied, 1)
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"bookstore_api/internal/infrastructure/http/controller"
+	"bookstore_api/tools"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes the private and public PEM files of the key, returning their paths
+func writeKeyPair(t *testing.T, signer crypto.Signer) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	private, err := x509.MarshalPKCS8PrivateKey(signer)
+	require.NoError(t, err)
+	public, err := x509.MarshalPKIXPublicKey(signer.Public())
+	require.NoError(t, err)
+
+	privatePath, publicPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub.pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}), 0o600))
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0o644))
+	return privatePath, publicPath
+}
+
+func TestAsymmetricTokens(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	edPrivate, edPublic := writeKeyPair(t, edKey)
+	rsaPrivate, _ := writeKeyPair(t, rsaKey)
+
+	t.Setenv("ISSUER", "bookstore")
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("JWT_SIGNING_KEY_ID", "")
+	t.Setenv("JWT_VERIFICATION_KEYS", "")
+	t.Setenv("JWT_ACCEPT_LEGACY_HS256", "true")
+
+	// Signed with the secret before the key pairs came in
+	t.Setenv("JWT_SIGNING_KEYS", "")
+	_, legacyToken, err := tools.GenerateToken(&tools.Principal{UserID: 7, Email: "user@example.com"}, tools.AccessToken, time.Minute)
+	require.NoError(t, err)
+
+	t.Setenv("JWT_SIGNING_KEYS", "ed1:"+edPrivate)
+	_, edToken, err := tools.GenerateToken(&tools.Principal{UserID: 7, Email: "user@example.com"}, tools.AccessToken, time.Minute)
+	require.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(edToken, &tools.CustomClaims{})
+	require.NoError(t, err)
+	require.Equal(t, "EdDSA", parsed.Method.Alg())
+	require.Equal(t, "ed1", parsed.Header["kid"])
+
+	t.Run("Rotates to a new key", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEYS", "rsa1:"+rsaPrivate)
+		t.Setenv("JWT_VERIFICATION_KEYS", "ed1:"+edPublic)
+
+		_, rsaToken, err := tools.GenerateToken(&tools.Principal{UserID: 7, Email: "user@example.com"}, tools.AccessToken, time.Minute)
+		require.NoError(t, err)
+		parsed, _, err := jwt.NewParser().ParseUnverified(rsaToken, &tools.CustomClaims{})
+		require.NoError(t, err)
+		require.Equal(t, "RS256", parsed.Method.Alg())
+
+		for _, token := range []string{rsaToken, edToken, legacyToken} {
+			claims, err := tools.ValidateToken(token, tools.AccessToken)
+			require.NoError(t, err)
+			require.Equal(t, int64(7), claims.UserID)
+		}
+	})
+
+	t.Run("Refuses legacy tokens unless opted in", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEYS", "ed1:"+edPrivate)
+		t.Setenv("JWT_ACCEPT_LEGACY_HS256", "")
+
+		_, err := tools.ValidateToken(legacyToken, tools.AccessToken)
+		require.Error(t, err)
+		_, err = tools.ValidateToken(edToken, tools.AccessToken)
+		require.NoError(t, err)
+
+		// Kid-less tokens are HS256 only, even with the fallback on
+		t.Setenv("JWT_ACCEPT_LEGACY_HS256", "true")
+		for _, method := range []jwt.SigningMethod{jwt.SigningMethodHS384, jwt.SigningMethodHS512} {
+			forged, err := jwt.NewWithClaims(method, &tools.CustomClaims{
+				UserID:           7,
+				Type:             tools.AccessToken,
+				RegisteredClaims: jwt.RegisteredClaims{Issuer: "bookstore", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+			}).SignedString([]byte("secret"))
+			require.NoError(t, err)
+
+			_, err = tools.ValidateToken(forged, tools.AccessToken)
+			require.Error(t, err)
+		}
+		_, err = tools.ValidateToken(legacyToken, tools.AccessToken)
+		require.NoError(t, err)
+
+		t.Setenv("JWT_ACCEPT_LEGACY_HS256", "yes please")
+		_, err = tools.TokenKeysFromEnv()
+		require.Error(t, err)
+	})
+
+	t.Run("Refuses keys it doesn't hold", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEYS", "rsa1:"+rsaPrivate)
+		t.Setenv("JWT_SECRET", "")
+
+		_, err := tools.ValidateToken(edToken, tools.AccessToken)
+		require.Error(t, err)
+		_, err = tools.ValidateToken(legacyToken, tools.AccessToken)
+		require.Error(t, err)
+	})
+
+	t.Run("Refuses a method the key isn't for", func(t *testing.T) {
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &tools.CustomClaims{
+			UserID:           7,
+			Type:             tools.AccessToken,
+			RegisteredClaims: jwt.RegisteredClaims{Issuer: "bookstore", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		})
+		forged.Header["kid"] = "ed1"
+		token, err := forged.SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		_, err = tools.ValidateToken(token, tools.AccessToken)
+		require.Error(t, err)
+	})
+
+	t.Run("Publishes the public keys", func(t *testing.T) {
+		t.Setenv("JWT_SIGNING_KEYS", "rsa1:"+rsaPrivate)
+		t.Setenv("JWT_VERIFICATION_KEYS", "ed1:"+edPublic)
+
+		rec := httptest.NewRecorder()
+		controller.JWKS(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		set := &tools.JWKS{}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(set))
+		require.Len(t, set.Keys, 2)
+		require.Equal(t, "rsa1", set.Keys[0].KeyID)
+		require.Equal(t, "RSA", set.Keys[0].KeyType)
+		require.Equal(t, "AQAB", set.Keys[0].Exponent)
+		require.Equal(t, "ed1", set.Keys[1].KeyID)
+		require.Equal(t, "OKP", set.Keys[1].KeyType)
+		require.Equal(t, "Ed25519", set.Keys[1].Curve)
+		require.NotContains(t, rec.Body.String(), "secret")
+	})
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"bookstore_api/tools"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{name: "ASCII", title: "Harry Potter: The Book", want: "harry-potter-the-book"},
+		{name: "Accents", title: "Pokémon Café", want: "pokemon-cafe"},
+		{name: "Special Latin", title: "Straße Łódź", want: "strasse-lodz"},
+		{name: "Cyrillic", title: "Война и мир", want: "voyna-i-mir"},
+		{name: "Katakana", title: "ワンピース", want: "wanpisu"},
+		{name: "Hiragana", title: "しんげきのきょじん", want: "shingekinokyojin"},
+		{name: "Sokuon", title: "ちゃっかり", want: "chakkari"},
+		{name: "Hangul", title: "나 혼자만 레벨업", want: "na-honjaman-rebeleop"},
+		{name: "Full Width", title: "ＡＢＣ１２３", want: "abc123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tools.Slugify(tt.title))
+		})
+	}
+
+	t.Run("Untransliterable", func(t *testing.T) {
+		slug := tools.Slugify("進撃の巨人")
+		require.Regexp(t, regexp.MustCompile(`^no-[0-9a-f]{8}$`), slug)
+		require.Equal(t, slug, tools.Slugify("進撃の巨人"))
+		require.NotEqual(t, slug, tools.Slugify("鬼滅の刃"))
+		require.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}$`), tools.Slugify("三国志"))
+	})
+}
+
+func TestUniqueSlug(t *testing.T) {
+	require.Equal(t, "naruto", tools.UniqueSlug("naruto", nil))
+	require.Equal(t, "naruto-2", tools.UniqueSlug("naruto", []string{"naruto"}))
+	require.Equal(t, "naruto-3", tools.UniqueSlug("naruto", []string{"naruto", "naruto-2", "naruto-shippuden"}))
+}
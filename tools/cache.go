@@ -11,7 +11,10 @@ import (
 // GetOrSetCacheById takes a key and value type, returns an encoded value
 // I don't want to do generics. This stops here.
 func getOrSetCacheById(c *redis.Client, ctx context.Context, id int, v interface{}, fn func(context.Context, int) (interface{}, error)) ([]byte, error) {
-	k := ctx.Value("cachedKey").(string)
+	k, ok := CacheKeyFromContext(ctx)
+	if !ok {
+		return nil, errors.New("cache key is not set")
+	}
 
 	// Returns cache if present
 	cachedJSON, err := c.Get(ctx, k).Result()
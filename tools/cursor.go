@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a keyset paginated listing, clients only ever see it encoded
+type Cursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Value      string `json:"v"`
+	ID         int64  `json:"i"`
+	Before     bool   `json:"b,omitempty"`
+}
+
+func (c *Cursor) Encode() string {
+	encoded, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// DecodeCursor reads an encoded cursor, it has to belong to a listing with the same sort
+func DecodeCursor(value string, sort string, descending bool) (*Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &Cursor{}
+	if err = json.Unmarshal(decoded, cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.Sort != sort || cursor.Descending != descending {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
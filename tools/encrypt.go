@@ -5,42 +5,56 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"io"
 )
 
 // Encrypt data using AES-GCM
 func Encrypt(data, key []byte) (string, error) {
+	ciphertext, err := seal(data, key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt data using AES-GCM
+func Decrypt(encryptedData string, key []byte) ([]byte, error) {
+	// Decode base64 string
+	data, err := base64.StdEncoding.DecodeString(encryptedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(data, key)
+}
+
+// seal encrypts the data and prepends the nonce
+func seal(data, key []byte) ([]byte, error) {
 	// Create a new AES cipher block
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Use GCM mode
 	aesGCM, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Create a nonce with GCM standard size
 	nonce := make([]byte, aesGCM.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Encrypt the data and prepend the nonce
-	ciphertext := aesGCM.Seal(nonce, nonce, data, nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return aesGCM.Seal(nonce, nonce, data, nil), nil
 }
 
-// Decrypt data using AES-GCM
-func Decrypt(encryptedData string, key []byte) ([]byte, error) {
-	// Decode base64 string
-	data, err := base64.StdEncoding.DecodeString(encryptedData)
-	if err != nil {
-		return nil, err
-	}
-
+// open decrypts what seal wrote
+func open(data, key []byte) ([]byte, error) {
 	// Create a new AES cipher block
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -55,6 +69,9 @@ func Decrypt(encryptedData string, key []byte) ([]byte, error) {
 
 	// Extract the nonce and ciphertext
 	nonceSize := aesGCM.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 
 	// Decrypt the data
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// envelopeVersion prefixes the ciphertexts an envelope writes, followed by the master key ID and the wrapped data key
+const envelopeVersion = "v2"
+
+// maxCachedDataKeys bounds how many unwrapped data keys an envelope holds on to
+const maxCachedDataKeys = 1024
+
+// Cipher is what encrypts the values stored at rest, either a keyring holding the keys itself or an envelope over a key manager
+type Cipher interface {
+	Encrypt(data []byte) (string, error)
+	Decrypt(ciphertext string) ([]byte, error)
+	NeedsRotation(ciphertext string) bool
+	Rotate(ciphertext string) (string, error)
+	CurrentKeyID() string
+}
+
+// Envelope encrypts with a data key from the key manager and stores the wrapped data key in the ciphertext,
+// so the master key never has to be in the process. Ciphertexts from before the envelope are read with the fallback keyring.
+type Envelope struct {
+	keyManager KeyManager
+	fallback   *Keyring
+
+	mu       sync.Mutex
+	current  *DataKey
+	dataKeys map[string][]byte
+}
+
+// NewEnvelope wraps the key manager, the fallback may be nil once nothing is left encrypted with the keyring
+func NewEnvelope(keyManager KeyManager, fallback *Keyring) *Envelope {
+	return &Envelope{
+		keyManager: keyManager,
+		fallback:   fallback,
+		dataKeys:   make(map[string][]byte),
+	}
+}
+
+// CipherFromEnv uses an envelope over the local key manager when KMS_MASTER_KEY_FILE is set, keeping the keyring
+// from AES_KEYS and AES_KEY around to read what was written before. Without it the keyring encrypts on its own.
+func CipherFromEnv() (Cipher, error) {
+	masterKeyFile := os.Getenv("KMS_MASTER_KEY_FILE")
+	if masterKeyFile == "" {
+		return KeyringFromEnv()
+	}
+
+	keyManager, err := NewLocalKeyManager(masterKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fallback, err := KeyringFromEnv()
+	if err != nil && !errors.Is(err, ErrKeyringEmpty) {
+		return nil, err
+	}
+
+	return NewEnvelope(keyManager, fallback), nil
+}
+
+func (e *Envelope) CurrentKeyID() string {
+	return e.keyManager.KeyID()
+}
+
+// dataKey hands out the data key to encrypt with, a new one is asked for once the master key has moved on
+func (e *Envelope) dataKey() (*DataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.current != nil && e.current.KeyID == e.keyManager.KeyID() {
+		return e.current, nil
+	}
+
+	dataKey, err := e.keyManager.GenerateDataKey(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error generating data key: %v", err)
+	}
+	e.current = dataKey
+
+	return dataKey, nil
+}
+
+// unwrap asks the key manager for the plaintext of a stored data key, once per data key
+func (e *Envelope) unwrap(keyID string, wrapped string) ([]byte, error) {
+	cacheKey := keyID + ":" + wrapped
+
+	e.mu.Lock()
+	key, ok := e.dataKeys[cacheKey]
+	e.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	key, err = e.keyManager.DecryptDataKey(context.Background(), keyID, decoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %w", err)
+	}
+
+	e.mu.Lock()
+	if len(e.dataKeys) >= maxCachedDataKeys {
+		clear(e.dataKeys)
+	}
+	e.dataKeys[cacheKey] = key
+	e.mu.Unlock()
+
+	return key, nil
+}
+
+// Encrypt writes v2:<master key id>:<base64 wrapped data key>:<base64 nonce and ciphertext>
+func (e *Envelope) Encrypt(data []byte) (string, error) {
+	dataKey, err := e.dataKey()
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := Encrypt(data, dataKey.Plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		envelopeVersion,
+		dataKey.KeyID,
+		base64.StdEncoding.EncodeToString(dataKey.Wrapped),
+		ciphertext,
+	}, ":"), nil
+}
+
+func (e *Envelope) Decrypt(ciphertext string) ([]byte, error) {
+	keyID, wrapped, data, ok := splitEnvelope(ciphertext)
+	if !ok {
+		if e.fallback == nil {
+			return nil, ErrUnknownKey
+		}
+		return e.fallback.Decrypt(ciphertext)
+	}
+
+	key, err := e.unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decrypt(data, key)
+}
+
+// NeedsRotation tells whether the ciphertext isn't under the current master key yet, keyring ciphertexts always are not
+func (e *Envelope) NeedsRotation(ciphertext string) bool {
+	keyID, _, _, ok := splitEnvelope(ciphertext)
+	return !ok || keyID != e.keyManager.KeyID()
+}
+
+// Rotate re-encrypts the ciphertext under the current master key, a ciphertext already under it comes back as is
+func (e *Envelope) Rotate(ciphertext string) (string, error) {
+	if !e.NeedsRotation(ciphertext) {
+		return ciphertext, nil
+	}
+
+	data, err := e.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return e.Encrypt(data)
+}
+
+// splitEnvelope takes an envelope ciphertext apart, ok is false for anything the envelope didn't write
+func splitEnvelope(ciphertext string) (keyID string, wrapped string, data string, ok bool) {
+	parts := strings.Split(ciphertext, ":")
+	if len(parts) != 4 || parts[0] != envelopeVersion {
+		return "", "", "", false
+	}
+	return parts[1], parts[2], parts[3], true
+}
@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrKeyringEmpty   = errors.New("no encryption key is set, set AES_KEYS or AES_KEY")
+	ErrKeyringConfig  = errors.New("AES_KEYS must be a comma separated list of id:base64 keys")
+	ErrInvalidKeySize = errors.New("encryption keys must be 16, 24 or 32 bytes")
+	ErrUnknownKey     = errors.New("ciphertext was encrypted with a key that isn't in the keyring")
+)
+
+// LegacyKeyID is the ID AES_KEY goes by, ciphertexts from before the keyring carry no ID and are read with it
+const LegacyKeyID = "legacy"
+
+// ciphertextVersion prefixes every ciphertext the keyring writes, followed by the key ID
+const ciphertextVersion = "v1"
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// Keyring encrypts with its current key and decrypts with whichever key a ciphertext names,
+// so keys can be rotated without making what was written before unreadable
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrKeyringEmpty
+	}
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("%w: invalid key id %q", ErrKeyringConfig, id)
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("%w: key %q", ErrInvalidKeySize, id)
+		}
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keyring", current)
+	}
+
+	return &Keyring{
+		current: current,
+		keys:    keys,
+	}, nil
+}
+
+// KeyringFromEnv builds the keyring from AES_KEYS, like "2024q4:<base64>,2025q1:<base64>", encrypting with AES_KEY_ID.
+// AES_KEY joins it as the legacy key, and on its own it is a keyring of one.
+func KeyringFromEnv() (*Keyring, error) {
+	keys := make(map[string][]byte)
+	var last string
+
+	if encodedKeys := os.Getenv("AES_KEYS"); encodedKeys != "" {
+		var err error
+		keys, last, err = parseKeys(strings.Split(encodedKeys, ","))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if encodedKey := os.Getenv("AES_KEY"); encodedKey != "" {
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, errors.New("failed to decode base64 key")
+		}
+		if _, ok := keys[LegacyKeyID]; !ok {
+			keys[LegacyKeyID] = key
+		}
+		if last == "" {
+			last = LegacyKeyID
+		}
+	}
+
+	// The last key listed is the newest one, unless another is picked
+	current := os.Getenv("AES_KEY_ID")
+	if current == "" {
+		current = last
+	}
+
+	return NewKeyring(current, keys)
+}
+
+// parseKeys reads id:base64 entries, it returns the keys along with the ID of the last one
+func parseKeys(entries []string) (map[string][]byte, string, error) {
+	keys := make(map[string][]byte, len(entries))
+	var last string
+
+	for _, entry := range entries {
+		id, encodedKey, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, "", ErrKeyringConfig
+		}
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: key %q is not base64", ErrKeyringConfig, id)
+		}
+		if _, ok := keys[id]; ok {
+			return nil, "", fmt.Errorf("%w: key %q is listed twice", ErrKeyringConfig, id)
+		}
+		keys[id] = key
+		last = id
+	}
+
+	return keys, last, nil
+}
+
+// CurrentKeyID is the ID of the key new ciphertexts are written with
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// Encrypt writes v1:<key id>:<base64 nonce and ciphertext> with the current key
+func (k *Keyring) Encrypt(data []byte) (string, error) {
+	ciphertext, err := Encrypt(data, k.keys[k.current])
+	if err != nil {
+		return "", err
+	}
+	return ciphertextVersion + ":" + k.current + ":" + ciphertext, nil
+}
+
+// Decrypt reads both versioned ciphertexts and the plain base64 ones from before the keyring
+func (k *Keyring) Decrypt(ciphertext string) ([]byte, error) {
+	id, data := keyID(ciphertext)
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	return Decrypt(data, key)
+}
+
+// NeedsRotation tells whether the ciphertext isn't written with the current key yet
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	id, _ := keyID(ciphertext)
+	return id != k.current
+}
+
+// Rotate re-encrypts the ciphertext with the current key, a ciphertext already using it comes back as is
+func (k *Keyring) Rotate(ciphertext string) (string, error) {
+	if !k.NeedsRotation(ciphertext) {
+		return ciphertext, nil
+	}
+
+	data, err := k.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return k.Encrypt(data)
+}
+
+// keyID splits a ciphertext into the ID of its key and the encrypted data, base64 never holds a colon
+func keyID(ciphertext string) (string, string) {
+	version, rest, ok := strings.Cut(ciphertext, ":")
+	if !ok || version != ciphertextVersion {
+		return LegacyKeyID, ciphertext
+	}
+
+	id, data, ok := strings.Cut(rest, ":")
+	if !ok {
+		return LegacyKeyID, ciphertext
+	}
+
+	return id, data
+}
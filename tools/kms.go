@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	ErrMasterKeyFile = errors.New("master key file must hold one id:base64 key per line")
+)
+
+// dataKeySize makes every data key an AES-256 key
+const dataKeySize = 32
+
+// DataKey is a key to encrypt data with, along with the same key wrapped by a master key that never leaves the key manager.
+// Only the wrapped key is stored, next to the data it encrypted.
+type DataKey struct {
+	KeyID     string
+	Plaintext []byte
+	Wrapped   []byte
+}
+
+// KeyManager issues data keys wrapped by its master keys and unwraps them again, the master keys themselves are never handed out
+type KeyManager interface {
+	// KeyID is the ID of the master key new data keys are wrapped with
+	KeyID() string
+	GenerateDataKey(ctx context.Context) (*DataKey, error)
+	DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyManager keeps its master keys in a file, it is meant for development and tests
+type LocalKeyManager struct {
+	keyring *Keyring
+}
+
+// NewLocalKeyManager reads the master keys from a file of id:base64 lines, the last one wraps new data keys.
+// Blank lines and lines starting with # are skipped.
+func NewLocalKeyManager(name string) (*LocalKeyManager, error) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading master key file: %v", err)
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if len(entries) == 0 {
+		return nil, ErrMasterKeyFile
+	}
+
+	keys, last, err := parseKeys(entries)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMasterKeyFile, err)
+	}
+
+	keyring, err := NewKeyring(last, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalKeyManager{
+		keyring: keyring,
+	}, nil
+}
+
+func (m *LocalKeyManager) KeyID() string {
+	return m.keyring.current
+}
+
+func (m *LocalKeyManager) GenerateDataKey(_ context.Context) (*DataKey, error) {
+	plaintext := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := seal(plaintext, m.keyring.keys[m.keyring.current])
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataKey{
+		KeyID:     m.keyring.current,
+		Plaintext: plaintext,
+		Wrapped:   wrapped,
+	}, nil
+}
+
+func (m *LocalKeyManager) DecryptDataKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := m.keyring.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	return open(wrapped, key)
+}
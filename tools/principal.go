@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+const (
+	principalKey ContextKey = "principal"
+	cacheKey     ContextKey = "cachedKey"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID    int64
+	Email     string
+	IsAdmin   bool
+	SessionID string
+}
+
+// WithPrincipal returns a copy of the context carrying the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext returns the principal of the context, ErrUnauthenticated if there is none
+func PrincipalFromContext(ctx context.Context) (*Principal, error) {
+	principal, ok := ctx.Value(principalKey).(*Principal)
+	if !ok || principal == nil {
+		return nil, ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+func WithCacheKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, cacheKey, key)
+}
+
+func CacheKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(cacheKey).(string)
+	return key, ok && key != ""
+}
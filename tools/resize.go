@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Flatten draws the image onto an opaque white RGBA canvas, transparent covers would turn black as JPEGs otherwise
+func Flatten(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// ResizeToWidth scales the flattened image down to the width keeping its aspect ratio, images that are already narrower
+// are returned as they are. Flattening once up front spares every rendition a full-size copy of the source.
+// Every pixel of the result averages the area of the source it covers, which keeps text on covers legible.
+func ResizeToWidth(flat *image.RGBA, width int) *image.RGBA {
+	srcW, srcH := flat.Bounds().Dx(), flat.Bounds().Dy()
+	if width <= 0 || srcW <= width {
+		return flat
+	}
+
+	height := max(1, srcH*width/srcW)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcH/height, max((y+1)*srcH/height, y*srcH/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcW/width, max((x+1)*srcW/width, x*srcW/width+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := flat.Pix[flat.PixOffset(flat.Rect.Min.X, flat.Rect.Min.Y+sy):]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					a += int(row[sx*4+3])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -39,10 +39,11 @@ func RespondWithJSONAndCache(w http.ResponseWriter, r *http.Request, client *red
 		return
 	}
 
-	cachedKey := r.Context().Value("cachedKey").(string)
-	err = client.SetEx(r.Context(), cachedKey, cachedJSON, time.Second*3600).Err()
-	if err != nil {
-		log.Printf("failed to cache data: %s", err)
+	if cachedKey, ok := CacheKeyFromContext(r.Context()); ok {
+		err = client.SetEx(r.Context(), cachedKey, cachedJSON, time.Second*3600).Err()
+		if err != nil {
+			log.Printf("failed to cache data: %s", err)
+		}
 	}
 
 	_, err = w.Write(cachedJSON)
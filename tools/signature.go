@@ -0,0 +1,24 @@
+package tools
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Sign returns the hex encoded HMAC-SHA256 of the body, prefixed the way providers send it
+func Sign(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the signature against the body in constant time
+func VerifySignature(body []byte, signature string, secret []byte) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+
+	return hmac.Equal([]byte(Sign(body, secret)), []byte(signature))
+}
@@ -0,0 +1,320 @@
+package tools
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrSigningConfig  = errors.New("JWT_SIGNING_KEYS and JWT_VERIFICATION_KEYS must be comma separated lists of id:path")
+	ErrSigningKeyType = errors.New("signing keys must be RSA keys of at least 2048 bits or Ed25519 keys")
+	ErrNoSigningKey   = errors.New("no signing key is set, set JWT_SIGNING_KEYS or JWT_SECRET")
+)
+
+// minRSABits is the smallest RSA key accepted for signing
+const minRSABits = 2048
+
+// verificationKey is a public key tokens are checked against, along with the only method it may verify
+type verificationKey struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// TokenKeys signs tokens with its current private key and verifies them with whichever key their kid names,
+// so a new key can start signing while tokens signed with the old one are still around
+type TokenKeys struct {
+	currentID string
+	current   crypto.Signer
+	method    jwt.SigningMethod
+	keys      map[string]*verificationKey
+
+	// secret is the HS256 secret from before the key pairs, tokens without a kid are checked with it.
+	// Once there are key pairs it is only kept when JWT_ACCEPT_LEGACY_HS256 opts in.
+	secret []byte
+}
+
+var tokenKeysCache struct {
+	sync.Mutex
+	config string
+	keys   *TokenKeys
+}
+
+// TokenKeysFromEnv loads the key pairs listed in JWT_SIGNING_KEYS, like "2025q1:keys/2025q1.pem", signing with
+// JWT_SIGNING_KEY_ID or else the last one. JWT_VERIFICATION_KEYS lists public keys that only verify, like retired ones.
+// Without signing keys tokens are signed with JWT_SECRET as before. With them JWT_SECRET only verifies older tokens,
+// and only while JWT_ACCEPT_LEGACY_HS256 is true, so anyone holding the secret can't keep minting tokens.
+// The keys are read once for as long as the variables stay the same.
+func TokenKeysFromEnv() (*TokenKeys, error) {
+	config := strings.Join([]string{
+		os.Getenv("JWT_SIGNING_KEYS"),
+		os.Getenv("JWT_SIGNING_KEY_ID"),
+		os.Getenv("JWT_VERIFICATION_KEYS"),
+		os.Getenv("JWT_SECRET"),
+		os.Getenv("JWT_ACCEPT_LEGACY_HS256"),
+	}, "\x00")
+
+	tokenKeysCache.Lock()
+	defer tokenKeysCache.Unlock()
+
+	if tokenKeysCache.keys != nil && tokenKeysCache.config == config {
+		return tokenKeysCache.keys, nil
+	}
+
+	keys, err := loadTokenKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	tokenKeysCache.config = config
+	tokenKeysCache.keys = keys
+	return keys, nil
+}
+
+func loadTokenKeys() (*TokenKeys, error) {
+	keys := &TokenKeys{
+		keys: make(map[string]*verificationKey),
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		keys.secret = []byte(secret)
+	}
+
+	signers := make(map[string]crypto.Signer)
+	var last string
+	err := readKeyFiles(os.Getenv("JWT_SIGNING_KEYS"), func(id string, block *pem.Block) error {
+		signer, err := parsePrivateKey(block)
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", id, err)
+		}
+		method, err := signingMethod(signer.Public())
+		if err != nil {
+			return fmt.Errorf("signing key %q: %w", id, err)
+		}
+
+		signers[id] = signer
+		keys.keys[id] = &verificationKey{method: method, public: signer.Public()}
+		last = id
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readKeyFiles(os.Getenv("JWT_VERIFICATION_KEYS"), func(id string, block *pem.Block) error {
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("verification key %q: %v", id, err)
+		}
+		method, err := signingMethod(public)
+		if err != nil {
+			return fmt.Errorf("verification key %q: %w", id, err)
+		}
+
+		keys.keys[id] = &verificationKey{method: method, public: public}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(signers) == 0 {
+		if keys.secret == nil {
+			return nil, ErrNoSigningKey
+		}
+		return keys, nil
+	}
+
+	// The last key listed is the newest one, unless another is picked
+	keys.currentID = os.Getenv("JWT_SIGNING_KEY_ID")
+	if keys.currentID == "" {
+		keys.currentID = last
+	}
+	current, ok := signers[keys.currentID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not in JWT_SIGNING_KEYS", keys.currentID)
+	}
+	keys.current = current
+	keys.method = keys.keys[keys.currentID].method
+
+	acceptLegacy := false
+	if value := os.Getenv("JWT_ACCEPT_LEGACY_HS256"); value != "" {
+		acceptLegacy, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_ACCEPT_LEGACY_HS256 must be true or false: %v", err)
+		}
+	}
+	if !acceptLegacy || keys.secret == nil {
+		keys.secret = nil
+		return keys, nil
+	}
+
+	log.Printf("tokens without a kid are still accepted when signed with JWT_SECRET, unset JWT_ACCEPT_LEGACY_HS256 once they have expired")
+	return keys, nil
+}
+
+// readKeyFiles calls fn with the first PEM block of every file of an id:path list
+func readKeyFiles(list string, fn func(id string, block *pem.Block) error) error {
+	if list == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(list, ",") {
+		id, path, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !keyIDPattern.MatchString(id) || path == "" {
+			return ErrSigningConfig
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading key %q: %v", id, err)
+		}
+		block, _ := pem.Decode(content)
+		if block == nil {
+			return fmt.Errorf("key %q is not PEM encoded", id)
+		}
+
+		err = fn(id, block)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrSigningKeyType
+	}
+	return signer, nil
+}
+
+// signingMethod picks RS256 for RSA keys and EdDSA for Ed25519 keys
+func signingMethod(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return nil, ErrSigningKeyType
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, ErrSigningKeyType
+	}
+}
+
+// Sign signs the claims with the current key and names it in the kid header, or with the secret when there are no keys
+func (k *TokenKeys) Sign(claims jwt.Claims) (string, error) {
+	if k.current == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.currentID
+	return token.SignedString(k.current)
+}
+
+// Keyfunc finds the key a token has to verify with, refusing any method other than the one that key is for
+func (k *TokenKeys) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		// Only HS256 ever signed tokens with the secret, HS384 and HS512 are refused like any other method
+		if token.Method != jwt.SigningMethodHS256 || k.secret == nil {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return k.secret, nil
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.public, nil
+}
+
+// JWK is a public key as JSON Web Key Sets describe it
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// RSA keys
+	Modulus  string `json:"n,omitempty"`
+	Exponent string `json:"e,omitempty"`
+
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// JWKS lists every public key tokens may be signed with, the secret is never part of it
+func (k *TokenKeys) JWKS() *JWKS {
+	set := &JWKS{Keys: make([]*JWK, 0, len(k.keys))}
+
+	for id, key := range k.keys {
+		jwk := &JWK{
+			KeyID:     id,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+		}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.Modulus = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.Exponent = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	// The current key goes first, the rest by ID so the document stays the same between requests
+	slices.SortFunc(set.Keys, func(a, b *JWK) int {
+		switch {
+		case a.KeyID == k.currentID:
+			return -1
+		case b.KeyID == k.currentID:
+			return 1
+		default:
+			return strings.Compare(a.KeyID, b.KeyID)
+		}
+	})
+
+	return set
+}
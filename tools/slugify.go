@@ -1,29 +1,185 @@
 package tools
 
 import (
-	"regexp"
+	"fmt"
+	"hash/fnv"
 	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Letters that don't decompose into ASCII on their own
+var latinLetters = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d",
+	'ł': "l", 'þ': "th", 'ı': "i", 'ŋ': "ng", 'ħ': "h", 'ŧ': "t",
+}
+
+var cyrillicLetters = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u", 'ј': "j", 'љ': "lj",
+	'њ': "nj", 'ћ': "c", 'ђ': "dj", 'џ': "dz",
+}
+
+var greekLetters = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+}
+
+// Hepburn romanization of hiragana, katakana is shifted onto it first
+var kanaSyllables = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o", 'ゎ': "wa",
+}
+
+// Revised Romanization of the jamo a Hangul syllable is made of
+var (
+	hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	hangulMedials  = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	hangulFinals   = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
 )
 
-// Slugify function to convert a string into a URL-friendly slug
+// Slugify function to convert a string into a URL-friendly slug.
+// Latin, Cyrillic, Greek, kana and Hangul are transliterated to ASCII. Scripts that can't be,
+// like Han characters, are replaced by a short hash of the whole string so the slug stays distinct.
 func Slugify(s string) string {
-	// Convert the string to lowercase
-	s = strings.ToLower(s)
+	var b strings.Builder
+	var untransliterable, doubleNext bool
+
+	separate := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "-") {
+			b.WriteByte('-')
+		}
+	}
+	write := func(ascii string) {
+		// A small tsu doubles the consonant after it
+		if doubleNext && ascii != "" && !strings.ContainsRune("aeiou", rune(ascii[0])) {
+			b.WriteByte(ascii[0])
+		}
+		doubleNext = false
+		b.WriteString(ascii)
+	}
 
-	// Replace spaces and underscores with a hyphen
-	s = strings.ReplaceAll(s, " ", "-")
-	s = strings.ReplaceAll(s, "_", "-")
+	for _, r := range norm.NFC.String(strings.ToLower(s)) {
+		// Katakana reads the same as hiragana
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= 0x60
+		}
 
-	// Remove all non-alphanumeric characters except for hyphens
-	re := regexp.MustCompile(`[^\w-]+`)
-	s = re.ReplaceAllString(s, "")
+		switch {
+		case r < unicode.MaxASCII:
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+				write(string(r))
+			case r == ' ', r == '_', r == '-', unicode.IsSpace(r):
+				separate()
+			}
+			// Any other ASCII punctuation is dropped, "Don't" becomes "dont"
+
+		case r == 'っ':
+			doubleNext = true
+
+		case r == 'ゃ' || r == 'ゅ' || r == 'ょ':
+			// Contracted sounds, き + ゃ is kya and し + ゃ is sha
+			vowel := map[rune]string{'ゃ': "a", 'ゅ': "u", 'ょ': "o"}[r]
+			current := b.String()
+			if strings.HasSuffix(current, "i") {
+				current = strings.TrimSuffix(current, "i")
+				if !strings.HasSuffix(current, "sh") && !strings.HasSuffix(current, "ch") && !strings.HasSuffix(current, "j") {
+					vowel = "y" + vowel
+				}
+				b.Reset()
+				b.WriteString(current)
+			} else {
+				vowel = "y" + vowel
+			}
+			write(vowel)
+
+		case r == 'ー':
+			// The long vowel mark is left out, like the macron would be
+
+		case kanaSyllables[r] != "":
+			write(kanaSyllables[r])
+
+		case r >= 0xAC00 && r <= 0xD7A3:
+			syllable := int(r - 0xAC00)
+			write(hangulInitials[syllable/588] + hangulMedials[(syllable%588)/28] + hangulFinals[syllable%28])
+
+		case latinLetters[r] != "" || cyrillicLetters[r] != "" || greekLetters[r] != "":
+			write(latinLetters[r] + cyrillicLetters[r] + greekLetters[r])
+
+		case r == 'ъ' || r == 'ь':
+			// Hard and soft signs have no sound of their own
+
+		default:
+			// Accented letters decompose into an ASCII letter followed by marks
+			var ascii strings.Builder
+			for _, d := range norm.NFKD.String(string(r)) {
+				switch {
+				case d >= 'a' && d <= 'z', d >= '0' && d <= '9':
+					ascii.WriteRune(d)
+				case d >= 'A' && d <= 'Z':
+					ascii.WriteRune(unicode.ToLower(d))
+				case greekLetters[d] != "" || cyrillicLetters[d] != "":
+					ascii.WriteString(greekLetters[d] + cyrillicLetters[d])
+				}
+			}
+
+			switch {
+			case ascii.Len() > 0:
+				write(ascii.String())
+			case unicode.IsLetter(r) || unicode.IsNumber(r):
+				untransliterable = true
+				separate()
+			case unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r):
+				separate()
+			}
+		}
+	}
+
+	slug := strings.Trim(b.String(), "-")
+	if !untransliterable {
+		return slug
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(s))
+	if slug == "" {
+		return fmt.Sprintf("%08x", hash.Sum32())
+	}
+	return fmt.Sprintf("%s-%08x", slug, hash.Sum32())
+}
 
-	// Replace multiple hyphens with a single hyphen
-	re = regexp.MustCompile(`-+`)
-	s = re.ReplaceAllString(s, "-")
+// UniqueSlug suffixes the slug with -2, -3 and so on until it is none of the taken ones
+func UniqueSlug(slug string, taken []string) string {
+	takenSet := make(map[string]bool, len(taken))
+	for _, t := range taken {
+		takenSet[t] = true
+	}
 
-	// Trim leading and trailing hyphens
-	s = strings.Trim(s, "-")
+	unique := slug
+	for i := 2; takenSet[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", slug, i)
+	}
 
-	return s
+	return unique
 }
@@ -2,7 +2,6 @@ package tools
 
 import (
 	"errors"
-	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"os"
@@ -11,23 +10,49 @@ import (
 
 type ContextKey string
 
+// TokenType tells access tokens from refresh tokens, each is only accepted where it is meant to be used
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
 type CustomClaims struct {
-	IsAdmin bool `json:"isAdmin,omitempty"`
+	UserID    int64     `json:"uid,omitempty"`
+	SessionID string    `json:"sid,omitempty"`
+	IsAdmin   bool      `json:"isAdmin,omitempty"`
+	Type      TokenType `json:"typ"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(email string, isAdmin bool, duration time.Duration) (*CustomClaims, string, error) {
+// Principal returns the caller the claims were issued to
+func (c *CustomClaims) Principal() *Principal {
+	return &Principal{
+		UserID:    c.UserID,
+		Email:     c.Subject,
+		IsAdmin:   c.IsAdmin,
+		SessionID: c.SessionID,
+	}
+}
+
+// GenerateToken issues a token of the type for the principal, its session ID ties access tokens to the session they came from.
+// It is signed with the current key of TokenKeysFromEnv.
+func GenerateToken(principal *Principal, tokenType TokenType, duration time.Duration) (*CustomClaims, string, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, "", errors.New("error generating token")
 	}
 
 	claims := &CustomClaims{
-		IsAdmin: isAdmin,
+		UserID:    principal.UserID,
+		SessionID: principal.SessionID,
+		IsAdmin:   principal.IsAdmin,
+		Type:      tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID: tokenID.String(),
 
-			Subject: email,
+			Subject: principal.Email,
 			Issuer:  os.Getenv("ISSUER"),
 
 			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(duration)),
@@ -35,8 +60,12 @@ func GenerateToken(email string, isAdmin bool, duration time.Duration) (*CustomC
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	keys, err := TokenKeysFromEnv()
+	if err != nil {
+		return nil, "", err
+	}
+
+	signedToken, err := keys.Sign(claims)
 	if err != nil {
 		return nil, "", err
 	}
@@ -44,17 +73,15 @@ func GenerateToken(email string, isAdmin bool, duration time.Duration) (*CustomC
 	return claims, signedToken, nil
 }
 
-func ValidateToken(tokenString string) (*CustomClaims, error) {
-	claims := &CustomClaims{}
-
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(key *jwt.Token) (interface{}, error) {
-		if _, ok := key.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", key.Header["alg"])
-		}
-
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// ValidateToken checks the token and that it is of the type expected, a refresh token is no good as an access token
+func ValidateToken(tokenString string, tokenType TokenType) (*CustomClaims, error) {
+	keys, err := TokenKeysFromEnv()
+	if err != nil {
+		return nil, errors.New("invalid credentials")
+	}
 
+	claims := &CustomClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc)
 	if err != nil {
 		return nil, errors.New("invalid credentials")
 	}
@@ -67,5 +94,9 @@ func ValidateToken(tokenString string) (*CustomClaims, error) {
 		return nil, errors.New("invalid credentials")
 	}
 
+	if claims.Type != tokenType {
+		return nil, errors.New("invalid credentials")
+	}
+
 	return claims, nil
 }